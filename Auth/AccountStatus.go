@@ -0,0 +1,61 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+)
+
+var (
+	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrAccountLocked      = errors.New("account is locked")
+	ErrAccountExpired     = errors.New("account has expired")
+	ErrCredentialsExpired = errors.New("credentials have expired")
+)
+
+// checkAccountStatus rejects users that may not authenticate at all, whatever the credentials.
+func checkAccountStatus(user *model.User) error {
+	if !user.IsEnabled() {
+		return ErrAccountDisabled
+	}
+	if !user.IsAccountNonLocked() {
+		return ErrAccountLocked
+	}
+	if !user.IsAccountNonExpired() {
+		return ErrAccountExpired
+	}
+	return nil
+}
+
+// checkCredentialsStatus reports users whose password must be changed before they may do anything else, either
+// flagged as expired or older than the maximum age of passwordPolicy, which may be nil.
+func checkCredentialsStatus(user *model.User, passwordPolicy *PasswordPolicy) error {
+	if !user.IsCredentialsNonExpired() {
+		return ErrCredentialsExpired
+	}
+	if passwordPolicy != nil && passwordPolicy.IsExpired(user) {
+		return ErrCredentialsExpired
+	}
+	return nil
+}
+
+// isAccountStatusError reports whether err is one of the account status errors above.
+func isAccountStatusError(err error) bool {
+	_, ok := accountStatusErrorCode(err)
+	return ok
+}
+
+// accountStatusErrorCode maps account status errors to the error codes used in responses.
+func accountStatusErrorCode(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrAccountDisabled):
+		return "account_disabled", true
+	case errors.Is(err, ErrAccountLocked):
+		return "account_locked", true
+	case errors.Is(err, ErrAccountExpired):
+		return "account_expired", true
+	case errors.Is(err, ErrCredentialsExpired):
+		return "credentials_expired", true
+	default:
+		return "", false
+	}
+}
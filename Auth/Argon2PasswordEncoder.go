@@ -0,0 +1,99 @@
+package Auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strconv"
+)
+
+// Argon2PasswordEncoder hashes passwords with argon2id, stored in PHC string format.
+// It also matches argon2i hashes, which UpgradeEncoding reports for replacement.
+// The defaults follow the OWASP recommendation of 19 MiB memory, 2 iterations and 1 degree of parallelism.
+type Argon2PasswordEncoder struct {
+	memory      uint32 // in KiB
+	iterations  uint32
+	parallelism uint8
+	saltLength  int
+	keyLength   uint32
+}
+
+func NewArgon2PasswordEncoder() *Argon2PasswordEncoder {
+	return &Argon2PasswordEncoder{
+		memory:      19 * 1024,
+		iterations:  2,
+		parallelism: 1,
+		saltLength:  16,
+		keyLength:   32,
+	}
+}
+
+// WithParameters sets the memory in KiB, the number of iterations and the degree of parallelism.
+func (e *Argon2PasswordEncoder) WithParameters(memory, iterations uint32, parallelism uint8) *Argon2PasswordEncoder {
+	e.memory = memory
+	e.iterations = iterations
+	e.parallelism = parallelism
+	return e
+}
+
+func (e *Argon2PasswordEncoder) Encode(rawPassword string) (string, error) {
+	salt, err := randomSalt(e.saltLength)
+	if err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(rawPassword), salt, e.iterations, e.memory, e.parallelism, e.keyLength)
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", e.memory, e.iterations, e.parallelism)
+
+	return formatPHC("argon2id", strconv.Itoa(argon2.Version), params, salt, hash), nil
+}
+
+func (e *Argon2PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	if (phc.id != "argon2id" && phc.id != "argon2i") || phc.version != strconv.Itoa(argon2.Version) {
+		return false, fmt.Errorf("%w: unsupported argon2 variant %s v=%s", ErrMalformedPasswordHash, phc.id, phc.version)
+	}
+
+	memory, err := phc.intParam("m")
+	if err != nil {
+		return false, err
+	}
+	iterations, err := phc.intParam("t")
+	if err != nil {
+		return false, err
+	}
+	parallelism, err := phc.intParam("p")
+	if err != nil || parallelism > 255 {
+		return false, fmt.Errorf("%w: invalid parameter p", ErrMalformedPasswordHash)
+	}
+
+	keyFunc := argon2.IDKey
+	if phc.id == "argon2i" {
+		keyFunc = argon2.Key
+	}
+	hash := keyFunc([]byte(rawPassword), phc.salt, uint32(iterations), uint32(memory), uint8(parallelism), uint32(len(phc.hash)))
+
+	return subtle.ConstantTimeCompare(hash, phc.hash) == 1, nil
+}
+
+func (e *Argon2PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil {
+		return false
+	}
+
+	if phc.id != "argon2id" || phc.version != strconv.Itoa(argon2.Version) {
+		return true
+	}
+
+	memory, _ := phc.intParam("m")
+	iterations, _ := phc.intParam("t")
+	parallelism, _ := phc.intParam("p")
+
+	return uint32(memory) < e.memory || uint32(iterations) < e.iterations || parallelism < int(e.parallelism) ||
+		len(phc.salt) < e.saltLength || uint32(len(phc.hash)) < e.keyLength
+}
@@ -2,21 +2,55 @@ package Auth
 
 import (
 	"errors"
+	"github.com/ayushs-2k4/go-security/model"
 	"log"
 	"net/http"
 	"regexp"
+	"time"
 )
 
 type AuthChain struct {
-	authMethods []AuthMethod
-	skipPaths   []*regexp.Regexp
+	authMethods        []AuthMethod
+	skipPaths          []*regexp.Regexp
+	entryPoint         EntryPoint
+	csrfProtection     *CSRFProtection
+	userStore          model.UserStore
+	passwordChangePath *regexp.Regexp
+
+	verifiedEmailUserStore model.UserStore
+	verifiedEmailPaths     []*regexp.Regexp
+}
+
+// userStoreProvider is implemented by authentication methods that look up users. NewAuthChain uses the store of
+// the first such method to check the account status of every principal.
+type userStoreProvider interface {
+	provideUserStore() model.UserStore
 }
 
+// NewAuthChain creates a chain that tries authMethods in order. If one of them looks up users, such as
+// UsernamePasswordAuth or BasicAuth, the account status check is enabled with its user store; see
+// WithAccountStatusCheck.
 func NewAuthChain(authMethods ...AuthMethod) *AuthChain {
-	return &AuthChain{
+	c := &AuthChain{
 		authMethods: authMethods,
 		skipPaths:   make([]*regexp.Regexp, 0),
 	}
+	c.entryPoint = &challengeEntryPoint{authChain: c}
+
+	for _, authMethod := range authMethods {
+		if provider, ok := authMethod.(userStoreProvider); ok {
+			c.userStore = provider.provideUserStore()
+			break
+		}
+	}
+
+	return c
+}
+
+// WithEntryPoint replaces how unauthenticated requests are answered, e.g. with a FormLogin redirect.
+func (c *AuthChain) WithEntryPoint(entryPoint EntryPoint) *AuthChain {
+	c.entryPoint = entryPoint
+	return c
 }
 
 // AddSkipPath allows adding regex patterns for paths that should skip authentication
@@ -44,15 +78,72 @@ func (c *AuthChain) AddSkipPaths(paths ...string) error {
 	return nil
 }
 
-func (c *AuthChain) authenticate(w http.ResponseWriter, r *http.Request) error {
-	// Check if the request path is in the skip paths
+// WithCSRFProtection checks every authenticated request against CSRF once authentication succeeded.
+func (c *AuthChain) WithCSRFProtection(csrfProtection *CSRFProtection) *AuthChain {
+	c.csrfProtection = csrfProtection
+	return c
+}
+
+// WithAccountStatusCheck looks up the authenticated principal after every successful authentication and
+// rejects disabled, locked and expired accounts, so that e.g. a JWT stops working as soon as its user is locked.
+// Access tokens issued before the user's password last changed are rejected as well.
+func (c *AuthChain) WithAccountStatusCheck(userStore model.UserStore) *AuthChain {
+	c.userStore = userStore
+	return c
+}
+
+// WithoutAccountStatusCheck disables the account status check, leaving it to token expiry and revocation.
+func (c *AuthChain) WithoutAccountStatusCheck() *AuthChain {
+	c.userStore = nil
+	return c
+}
+
+// SetPasswordChangePath sets the regex pattern of the only path users who must change their password may reach.
+// Without it, such users are rejected everywhere.
+func (c *AuthChain) SetPasswordChangePath(path string) error {
+	regexPath, err := regexp.Compile(path)
+	if err != nil {
+		return err
+	}
+
+	c.passwordChangePath = regexPath
+	return nil
+}
+
+// RequireVerifiedEmail rejects authenticated users whose email is not verified on paths matching the given
+// regex patterns. The user is looked up in userStore on every matching request.
+func (c *AuthChain) RequireVerifiedEmail(userStore model.UserStore, paths ...string) error {
+	for _, path := range paths {
+		regexPath, err := regexp.Compile(path)
+		if err != nil {
+			return err
+		}
+
+		c.verifiedEmailPaths = append(c.verifiedEmailPaths, regexPath)
+	}
+
+	c.verifiedEmailUserStore = userStore
+	return nil
+}
+
+func (c *AuthChain) isSkipPath(r *http.Request) bool {
 	for _, skipPath := range c.skipPaths {
 		if skipPath.MatchString(r.URL.Path) {
-			return nil // Skip authentication for this path
+			return true
 		}
 	}
 
+	return false
+}
+
+func (c *AuthChain) authenticate(w http.ResponseWriter, r *http.Request) error {
+	// Check if the request path is in the skip paths
+	if c.isSkipPath(r) {
+		return nil // Skip authentication for this path
+	}
+
 	havePassed := false
+	errs := []error{ErrAuthenticationFailed}
 
 	for _, authMethod := range c.authMethods {
 		ok, err := authMethod.Authenticate(w, r)
@@ -63,27 +154,167 @@ func (c *AuthChain) authenticate(w http.ResponseWriter, r *http.Request) error {
 		}
 		if err != nil {
 			log.Printf("Authentication failed in authMethod: %T, error: %v", authMethod, err)
+			errs = append(errs, err)
 		}
 	}
 
 	if havePassed {
-		return nil // All authentication methods passed
+		err := c.checkAccountStatus(r)
+		if err != nil {
+			return err
+		}
+		err = c.checkPasswordChangeRestriction(r)
+		if err != nil {
+			return err
+		}
+		return c.checkEmailVerified(r)
 	}
 
-	return errors.New("authentication failed")
+	// Keep the individual errors so the entry point can tell e.g. a throttled login apart
+	return errors.Join(errs...)
 
 }
 
+// checkAccountStatus validates the current state of the authenticated user, if enabled.
+func (c *AuthChain) checkAccountStatus(r *http.Request) error {
+	principal := PrincipalFromRequest(r)
+	if c.userStore == nil || principal == nil {
+		return nil
+	}
+
+	user, err := c.userStore.FindUserByUsername(principal.Subject)
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+		return errors.Join(ErrAuthenticationFailed, ErrAccountDisabled) // The user has been deleted
+	}
+	if err != nil {
+		return err
+	}
+
+	err = checkAccountStatus(user)
+	if err != nil {
+		return errors.Join(ErrAuthenticationFailed, err)
+	}
+
+	// Changing the password signs the user out of every token issued before. Token timestamps have whole
+	// seconds, so tokens issued in the second of the change stay valid.
+	if !principal.IssuedAt.IsZero() && principal.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		return errors.Join(ErrAuthenticationFailed, ErrTokenRevoked)
+	}
+
+	return nil
+}
+
+// checkPasswordChangeRestriction confines password-change-only principals to the password change path.
+func (c *AuthChain) checkPasswordChangeRestriction(r *http.Request) error {
+	principal := PrincipalFromRequest(r)
+	if principal == nil || !principal.PasswordChangeOnly {
+		return nil
+	}
+
+	if c.passwordChangePath != nil && c.passwordChangePath.MatchString(r.URL.Path) {
+		return nil
+	}
+
+	return ErrPasswordChangeRequired
+}
+
+// checkEmailVerified rejects users with an unverified email on the paths configured with RequireVerifiedEmail.
+func (c *AuthChain) checkEmailVerified(r *http.Request) error {
+	principal := PrincipalFromRequest(r)
+	if c.verifiedEmailUserStore == nil || principal == nil {
+		return nil
+	}
+
+	required := false
+	for _, path := range c.verifiedEmailPaths {
+		if path.MatchString(r.URL.Path) {
+			required = true
+			break
+		}
+	}
+	if !required {
+		return nil
+	}
+
+	user, err := c.verifiedEmailUserStore.FindUserByUsername(principal.Subject)
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+		return ErrEmailNotVerified
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// challenge lets every auth method that supports it tell the client how to authenticate.
+func (c *AuthChain) challenge(w http.ResponseWriter) {
+	for _, authMethod := range c.authMethods {
+		if challenger, ok := authMethod.(Challenger); ok {
+			challenger.Challenge(w)
+		}
+	}
+}
+
+// challengeEntryPoint is the default EntryPoint: it challenges the client and answers 401.
+type challengeEntryPoint struct {
+	authChain *AuthChain
+}
+
+func (e *challengeEntryPoint) Commence(w http.ResponseWriter, r *http.Request, err error) {
+	var throttledErr *LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		throttledErr.setRetryAfter(w)
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	// The user is authenticated, just not allowed here until they act
+	if errors.Is(err, ErrPasswordChangeRequired) {
+		http.Error(w, "Forbidden: password_change_required", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrEmailNotVerified) {
+		http.Error(w, "Forbidden: email_not_verified", http.StatusForbidden)
+		return
+	}
+
+	e.authChain.challenge(w)
+
+	if code, ok := accountStatusErrorCode(err); ok {
+		http.Error(w, "Unauthorized: "+code, http.StatusUnauthorized)
+		return
+	}
+
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
 func AuthMiddleware(authChain *AuthChain, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Give the auth methods somewhere to record the principal for the handlers further down
+		r = withSecurityContext(r)
+
 		err := authChain.authenticate(w, r)
 
 		if err != nil {
 			log.Printf("Authentication failed: %v", err)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			authChain.entryPoint.Commence(w, r, err)
 			return
 		}
 
+		if authChain.csrfProtection != nil && !authChain.isSkipPath(r) {
+			r, err = authChain.csrfProtection.protect(w, r)
+			if err != nil {
+				log.Printf("CSRF protection rejected request: %v", err)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,50 @@
+package Auth
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BCryptPasswordEncoder hashes passwords with bcrypt.
+type BCryptPasswordEncoder struct {
+	cost int
+}
+
+func NewBCryptPasswordEncoder() *BCryptPasswordEncoder {
+	return &BCryptPasswordEncoder{
+		cost: bcrypt.DefaultCost,
+	}
+}
+
+// WithCost sets the bcrypt cost, between bcrypt.MinCost and bcrypt.MaxCost.
+func (e *BCryptPasswordEncoder) WithCost(cost int) *BCryptPasswordEncoder {
+	e.cost = cost
+	return e
+}
+
+func (e *BCryptPasswordEncoder) Encode(rawPassword string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(rawPassword), e.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (e *BCryptPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedPassword))
+	if err != nil {
+		return false
+	}
+	return cost < e.cost
+}
+
+func (e *BCryptPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedPassword), []byte(rawPassword))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// BasicAuth implements HTTP Basic authentication (RFC 7617) against a UserStore.
+type BasicAuth struct {
+	credentialVerifier *CredentialVerifier
+	realm              string
+}
+
+func NewBasicAuth(userStore model.UserStore, realm string) *BasicAuth {
+	return &BasicAuth{
+		credentialVerifier: NewCredentialVerifier(userStore),
+		realm:              realm,
+	}
+}
+
+// WithCredentialVerifier replaces the verifier, e.g. to use a different PasswordEncoder.
+func (b *BasicAuth) WithCredentialVerifier(credentialVerifier *CredentialVerifier) *BasicAuth {
+	b.credentialVerifier = credentialVerifier
+	return b
+}
+
+func (b *BasicAuth) provideUserStore() model.UserStore {
+	return b.credentialVerifier.userStore
+}
+
+func (b *BasicAuth) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false, errors.New("missing or malformed Basic credentials")
+	}
+
+	// We advertise charset="UTF-8", so anything else cannot match a stored user
+	if !utf8.ValidString(username) || !utf8.ValidString(password) {
+		return false, ErrInvalidCredentials
+	}
+
+	user, err := b.credentialVerifier.Verify(r, username, password)
+	if err != nil {
+		return false, err
+	}
+
+	setPrincipal(r, &Principal{
+		Subject:            user.Username,
+		PasswordChangeOnly: user.MustChangePassword,
+	})
+
+	return true, nil
+}
+
+// Challenge asks the client for Basic credentials, announcing that they are UTF-8 encoded.
+func (b *BasicAuth) Challenge(w http.ResponseWriter) {
+	w.Header().Add("WWW-Authenticate", `Basic realm="`+quoteHeaderValue(b.realm)+`", charset="UTF-8"`)
+}
+
+// quoteHeaderValue escapes a value for use inside an HTTP quoted-string.
+func quoteHeaderValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
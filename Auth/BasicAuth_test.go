@@ -0,0 +1,75 @@
+package Auth
+
+import (
+	"encoding/base64"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	for username, password := range map[string]string{"alice": "alice password", "jürgen": "pässwörd"} {
+		hashedPassword, err := NewDefaultPasswordEncoder().Encode(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		userStore.SaveUser(&model.User{Username: username, Password: hashedPassword})
+	}
+
+	var subject string
+	handler := AuthMiddleware(NewAuthChain(NewBasicAuth(userStore, `Staff "only"`)), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = PrincipalFromRequest(r).Subject
+	}))
+
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		subject       string
+	}{
+		{"valid credentials", basic("alice:alice password"), "alice"},
+		{"lowercase scheme", "basic " + base64.StdEncoding.EncodeToString([]byte("alice:alice password")), "alice"},
+		{"UTF-8 credentials", basic("jürgen:pässwörd"), "jürgen"},
+		{"ISO-8859-1 credentials", basic("j\xfcrgen:p\xe4ssw\xf6rd"), ""},
+		{"wrong password", basic("alice:wrong password"), ""},
+		{"unknown user", basic("mallory:alice password"), ""},
+		{"missing colon", basic("alice"), ""},
+		{"malformed base64", "Basic alice:alice password", ""},
+		{"missing credentials", "Basic", ""},
+		{"other scheme", "Bearer " + base64.StdEncoding.EncodeToString([]byte("alice:alice password")), ""},
+		{"missing header", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if tt.subject != "" {
+				if w.Code != http.StatusOK || subject != tt.subject {
+					t.Fatalf("got status %d as %q, want %d as %q", w.Code, subject, http.StatusOK, tt.subject)
+				}
+				return
+			}
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			want := `Basic realm="Staff \"only\"", charset="UTF-8"`
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge != want {
+				t.Fatalf("got challenge %q, want %q", challenge, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package Auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswordChecker reports whether a password is known from a data breach.
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// BreachedPasswordList checks passwords against a list loaded into memory, such as a common-passwords file.
+type BreachedPasswordList struct {
+	passwords map[string]struct{}
+}
+
+// LoadBreachedPasswordList reads a file holding one password per line.
+func LoadBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedPasswordList{
+		passwords: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		password := strings.TrimRight(scanner.Text(), "\r")
+		if password != "" {
+			list.passwords[password] = struct{}{}
+		}
+	}
+
+	return list, scanner.Err()
+}
+
+func (l *BreachedPasswordList) IsBreached(password string) (bool, error) {
+	_, ok := l.passwords[password]
+	return ok, nil
+}
+
+// HashPrefixBreachChecker looks passwords up in a local copy of a k-anonymity range corpus, as published by
+// Have I Been Pwned: the directory holds one file per 5 hex character SHA-1 prefix (e.g. "21BD1" or
+// "21BD1.txt"), each listing the remaining 35 characters of every breached hash as "SUFFIX:COUNT" lines.
+// Only the file of the password's prefix is ever read.
+type HashPrefixBreachChecker struct {
+	directory string
+	minCount  int
+}
+
+func NewHashPrefixBreachChecker(directory string) *HashPrefixBreachChecker {
+	return &HashPrefixBreachChecker{
+		directory: directory,
+		minCount:  1,
+	}
+}
+
+// WithMinCount only reports passwords seen at least minCount times in breaches.
+func (c *HashPrefixBreachChecker) WithMinCount(minCount int) *HashPrefixBreachChecker {
+	c.minCount = minCount
+	return c
+}
+
+func (c *HashPrefixBreachChecker) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(c.directory, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(c.directory, prefix+".txt"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil // No breached hash shares this prefix
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		return parseBreachCount(count) >= c.minCount, nil
+	}
+
+	return false, scanner.Err()
+}
+
+// parseBreachCount treats a missing or malformed count as a single occurrence.
+func parseBreachCount(count string) int {
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return 1
+	}
+	return max(n, 1)
+}
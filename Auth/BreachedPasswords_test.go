@@ -0,0 +1,108 @@
+package Auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeBreachedPasswordList(t *testing.T, content string) *BreachedPasswordList {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedPasswordList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestBreachedPasswordList(t *testing.T) {
+	list := writeBreachedPasswordList(t, "password\r\n123456\r\n\r\nqwerty \n")
+
+	tests := []struct {
+		password string
+		breached bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"qwerty ", true}, // Only line endings are trimmed
+		{"qwerty", false},
+		{"Password", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		breached, err := list.IsBreached(tt.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if breached != tt.breached {
+			t.Errorf("IsBreached(%q) = %v, want %v", tt.password, breached, tt.breached)
+		}
+	}
+
+	_, err := LoadBreachedPasswordList(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil {
+		t.Fatal("loading a missing list succeeded")
+	}
+}
+
+// The SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+const passwordHashSuffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestHashPrefixBreachChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		minCount int
+		breached bool
+	}{
+		{"listed", "5BAA6", "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + passwordHashSuffix + ":3861493\n", 1, true},
+		{"listed in a .txt file", "5BAA6.txt", passwordHashSuffix + ":3861493\n", 1, true},
+		{"lowercase suffix and CRLF", "5BAA6", "1e4c9b93f3f0682250b6cf8331b7ee68fd8:2\r\n", 1, true},
+		{"not listed", "5BAA6", "0018A45C4D1DEF81644B54AB7F969B88D65:1\n", 1, false},
+		{"no file for the prefix", "21BD1", passwordHashSuffix + ":1\n", 1, false},
+		{"seen too rarely", "5BAA6", passwordHashSuffix + ":4\n", 5, false},
+		{"seen often enough", "5BAA6", passwordHashSuffix + ":5\n", 5, true},
+		{"malformed count counts once", "5BAA6", passwordHashSuffix + ":many\n", 1, true},
+		{"missing count counts once", "5BAA6", passwordHashSuffix + "\n", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			err := os.WriteFile(filepath.Join(directory, tt.file), []byte(tt.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			breached, err := NewHashPrefixBreachChecker(directory).WithMinCount(tt.minCount).IsBreached("password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if breached != tt.breached {
+				t.Fatalf("got breached %v, want %v", breached, tt.breached)
+			}
+		})
+	}
+}
+
+func TestHashPrefixBreachCheckerReportsUnreadableFiles(t *testing.T) {
+	directory := t.TempDir()
+	err := os.Mkdir(filepath.Join(directory, "5BAA6"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewHashPrefixBreachChecker(directory).IsBreached("password")
+	if err == nil {
+		t.Fatal("an unreadable prefix file was treated as not breached")
+	}
+}
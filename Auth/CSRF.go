@@ -0,0 +1,252 @@
+package Auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrCSRFTokenMissing   = errors.New("missing CSRF token")
+	ErrCSRFTokenMismatch  = errors.New("CSRF token mismatch")
+	ErrCSRFOriginMismatch = errors.New("request origin is not trusted")
+)
+
+const csrfSessionAttribute = "csrf_token"
+
+// CSRFMode selects where the expected CSRF token is kept.
+type CSRFMode int
+
+const (
+	// SynchronizerTokenMode stores the token in the server-side session; requires SessionAuth.
+	// Requests without a session, such as the login form, fall back to DoubleSubmitCookieMode.
+	SynchronizerTokenMode CSRFMode = iota
+	// DoubleSubmitCookieMode stores the token in a cookie the client has to echo back.
+	DoubleSubmitCookieMode
+)
+
+// CSRFProtection guards cookie-authenticated, state-changing requests against cross-site request forgery.
+// Enable it with AuthChain.WithCSRFProtection; it runs after authentication on every path that is not skipped.
+//
+// Safe methods and requests authenticated with a Bearer or DPoP Authorization header, which browsers never
+// attach on their own, are let through. Everything else must come from a trusted Origin (or Referer) and carry
+// the token returned by CSRFToken in the X-CSRF-Token header or the csrf_token form field.
+//
+// Paths skipped by the chain, like the login form, aren't checked; wrap their handlers with Protect instead.
+type CSRFProtection struct {
+	mode           CSRFMode
+	sessionStore   Store.SessionStore
+	headerName     string
+	formField      string
+	cookieName     string
+	trustedOrigins map[string]bool
+	externalURL    *url.URL
+}
+
+func NewSynchronizerTokenCSRF(sessionStore Store.SessionStore) *CSRFProtection {
+	return newCSRFProtection(SynchronizerTokenMode, sessionStore)
+}
+
+func NewDoubleSubmitCSRF() *CSRFProtection {
+	return newCSRFProtection(DoubleSubmitCookieMode, nil)
+}
+
+func newCSRFProtection(mode CSRFMode, sessionStore Store.SessionStore) *CSRFProtection {
+	return &CSRFProtection{
+		mode:           mode,
+		sessionStore:   sessionStore,
+		headerName:     "X-CSRF-Token",
+		formField:      "csrf_token",
+		cookieName:     "__Host-csrf",
+		trustedOrigins: make(map[string]bool),
+	}
+}
+
+// WithTrustedOrigins allows cross-origin requests from the given origins, e.g. "https://admin.example.com".
+// The origin of the request's own host is always trusted.
+func (c *CSRFProtection) WithTrustedOrigins(origins ...string) *CSRFProtection {
+	for _, origin := range origins {
+		c.trustedOrigins[strings.ToLower(origin)] = true
+	}
+	return c
+}
+
+// WithExternalURL sets the public base URL of the server, e.g. "https://app.example.com", whose origin counts
+// as same-origin. It is needed behind a TLS-terminating proxy, where the request's own scheme is plain http.
+func (c *CSRFProtection) WithExternalURL(externalURL *url.URL) *CSRFProtection {
+	c.externalURL = externalURL
+	return c
+}
+
+type csrfContextKey struct{}
+
+type csrfRequestState struct {
+	protection *CSRFProtection
+	w          http.ResponseWriter
+	r          *http.Request
+	token      string
+}
+
+// CSRFToken returns the token to embed in forms or send in the X-CSRF-Token header, creating it if needed.
+// It returns "" for requests that did not pass through CSRFProtection.
+func CSRFToken(r *http.Request) string {
+	state, ok := r.Context().Value(csrfContextKey{}).(*csrfRequestState)
+	if !ok {
+		return ""
+	}
+
+	if state.token == "" {
+		token, err := state.protection.issueToken(state.w, state.r)
+		if err != nil {
+			log.Printf("Failed to issue CSRF token: %v", err)
+			return ""
+		}
+		state.token = token
+	}
+
+	return state.token
+}
+
+// Protect applies the CSRF check to a handler on an AuthChain skip path, e.g. FormLogin and the login page it
+// belongs to, so that an attacker can't log a victim into the attacker's account (login CSRF).
+func (c *CSRFProtection) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := c.protect(w, r)
+		if err != nil {
+			log.Printf("CSRF protection rejected request: %v", err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// protect verifies the request and attaches the state CSRFToken needs to it.
+func (c *CSRFProtection) protect(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	state := &csrfRequestState{protection: c, w: w}
+	r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, state))
+	state.r = r
+	state.token = c.expectedToken(r)
+
+	if isSafeMethod(r.Method) || isBearerRequest(r) {
+		return r, nil
+	}
+
+	err := c.verifyOrigin(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.token == "" {
+		return nil, ErrCSRFTokenMissing
+	}
+
+	actualToken := r.Header.Get(c.headerName)
+	if actualToken == "" {
+		actualToken = r.PostFormValue(c.formField)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(actualToken), []byte(state.token)) != 1 {
+		return nil, ErrCSRFTokenMismatch
+	}
+
+	return r, nil
+}
+
+// expectedToken returns the token previously issued to the client, or "".
+func (c *CSRFProtection) expectedToken(r *http.Request) string {
+	principal := PrincipalFromRequest(r)
+	if c.mode == DoubleSubmitCookieMode || principal == nil || principal.Session == nil {
+		cookie, err := r.Cookie(c.cookieName)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+
+	return principal.Session.Attributes[csrfSessionAttribute]
+}
+
+func (c *CSRFProtection) issueToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	principal := PrincipalFromRequest(r)
+	if c.mode == DoubleSubmitCookieMode || principal == nil || principal.Session == nil {
+		// Deliberately readable from JavaScript, the client has to copy it into a header
+		http.SetCookie(w, &http.Cookie{
+			Name:     c.cookieName,
+			Value:    token,
+			Path:     "/",
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return token, nil
+	}
+
+	session := principal.Session
+	// Only update a session that still exists, saving it would bring it back after a concurrent logout
+	err = c.sessionStore.SetAttribute(session.ID, csrfSessionAttribute, token)
+	if err != nil {
+		return "", err
+	}
+
+	if session.Attributes == nil {
+		session.Attributes = make(map[string]string)
+	}
+	session.Attributes[csrfSessionAttribute] = token
+
+	return token, nil
+}
+
+// verifyOrigin checks the Origin header, falling back to the Referer when a browser omits Origin.
+func (c *CSRFProtection) verifyOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return nil // Neither is sent, the token check alone decides
+		}
+
+		refererURL, err := url.Parse(referer)
+		if err != nil {
+			return ErrCSRFOriginMismatch
+		}
+		origin = refererURL.Scheme + "://" + refererURL.Host
+	}
+
+	origin = strings.ToLower(origin)
+	if origin == c.requestOrigin(r) || c.trustedOrigins[origin] {
+		return nil
+	}
+
+	return ErrCSRFOriginMismatch
+}
+
+func (c *CSRFProtection) requestOrigin(r *http.Request) string {
+	requestURL := externalURL(r, c.externalURL)
+	return strings.ToLower(requestURL.Scheme + "://" + requestURL.Host)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// isBearerRequest reports whether the request carries a token browsers would never attach automatically.
+func isBearerRequest(r *http.Request) bool {
+	scheme, credentials := obtainAuthorization(r)
+	return credentials != "" && (strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "DPoP"))
+}
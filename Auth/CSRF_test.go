@@ -0,0 +1,281 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSession saves a fresh session of subject and returns its ID.
+func newTestSession(t *testing.T, sessionStore Store.SessionStore, subject string) string {
+	t.Helper()
+
+	now := time.Now()
+	session := &model.Session{
+		ID:             subject + "-session",
+		Subject:        subject,
+		CreatedAt:      now,
+		LastAccessedAt: now,
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	err := sessionStore.Save(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session.ID
+}
+
+// newCSRFTestRouter serves the CSRF token on GET /form and accepts POST /action.
+func newCSRFTestRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /form", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r)))
+	})
+	router.HandleFunc("/action", func(w http.ResponseWriter, r *http.Request) {})
+	return router
+}
+
+func TestSynchronizerTokenCSRF(t *testing.T) {
+	sessionStore := Store.NewInMemorySessionStore()
+	sessionID := newTestSession(t, sessionStore, "alice")
+	authChain := NewAuthChain(NewJWTAuth(testSecret), NewSessionAuth(sessionStore)).
+		WithCSRFProtection(NewSynchronizerTokenCSRF(sessionStore))
+	handler := AuthMiddleware(authChain, newCSRFTestRouter())
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req.AddCookie(&http.Cookie{Name: "__Host-session", Value: sessionID})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	form := serve(httptest.NewRequest("GET", "/form", nil))
+	token := form.Body.String()
+	if form.Code != http.StatusOK || token == "" {
+		t.Fatalf("GET /form: got status %d and token %q", form.Code, token)
+	}
+
+	session, err := sessionStore.Find(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Attributes[csrfSessionAttribute] != token {
+		t.Fatalf("session keeps token %q, want %q", session.Attributes[csrfSessionAttribute], token)
+	}
+
+	// The token stays the same for the whole session
+	if again := serve(httptest.NewRequest("GET", "/form", nil)).Body.String(); again != token {
+		t.Fatalf("second GET /form: got token %q, want %q", again, token)
+	}
+
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+	}{
+		{"missing token", func() *http.Request {
+			return httptest.NewRequest("POST", "/action", nil)
+		}, http.StatusForbidden},
+		{"mismatched token", func() *http.Request {
+			req := httptest.NewRequest("POST", "/action", nil)
+			req.Header.Set("X-CSRF-Token", token+"x")
+			return req
+		}, http.StatusForbidden},
+		{"token in header", func() *http.Request {
+			req := httptest.NewRequest("POST", "/action", nil)
+			req.Header.Set("X-CSRF-Token", token)
+			return req
+		}, http.StatusOK},
+		{"token in form", func() *http.Request {
+			req := httptest.NewRequest("POST", "/action", strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}, http.StatusOK},
+		{"token from another origin", func() *http.Request {
+			req := httptest.NewRequest("POST", "/action", nil)
+			req.Header.Set("X-CSRF-Token", token)
+			req.Header.Set("Origin", "https://evil.example")
+			return req
+		}, http.StatusForbidden},
+		{"token from the same origin", func() *http.Request {
+			req := httptest.NewRequest("POST", "/action", nil)
+			req.Header.Set("X-CSRF-Token", token)
+			req.Header.Set("Origin", "http://example.com")
+			return req
+		}, http.StatusOK},
+		{"safe methods need no token", func() *http.Request {
+			return httptest.NewRequest("HEAD", "/action", nil)
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(tt.req()); w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCSRFSkipsSafeMethodsAndBearerRequests(t *testing.T) {
+	sessionStore := Store.NewInMemorySessionStore()
+	authChain := NewAuthChain(NewJWTAuth(testSecret), NewSessionAuth(sessionStore)).
+		WithCSRFProtection(NewSynchronizerTokenCSRF(sessionStore))
+	handler := AuthMiddleware(authChain, newCSRFTestRouter())
+
+	accessToken, _, err := GenerateJWT("alice", testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "TRACE", "POST", "PUT", "DELETE"} {
+		req := httptest.NewRequest(method, "/action", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s with a bearer token: got status %d, want %d", method, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestSynchronizerTokenCSRFDoesNotResurrectDeletedSession(t *testing.T) {
+	sessionStore := Store.NewInMemorySessionStore()
+	sessionID := newTestSession(t, sessionStore, "alice")
+	authChain := NewAuthChain(NewSessionAuth(sessionStore)).
+		WithCSRFProtection(NewSynchronizerTokenCSRF(sessionStore))
+
+	var token string
+	handler := AuthMiddleware(authChain, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A logout completing while this request is served
+		err := sessionStore.Delete(sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token = CSRFToken(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "__Host-session", Value: sessionID})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if token != "" {
+		t.Fatalf("got token %q for a deleted session, want none", token)
+	}
+	if _, err := sessionStore.Find(sessionID); !errors.Is(err, Store.ErrSessionNotFound) {
+		t.Fatalf("finding the deleted session: got error %v, want %v", err, Store.ErrSessionNotFound)
+	}
+}
+
+func TestDoubleSubmitCSRF(t *testing.T) {
+	handler := NewDoubleSubmitCSRF().Protect(newCSRFTestRouter())
+
+	form := httptest.NewRecorder()
+	handler.ServeHTTP(form, httptest.NewRequest("GET", "/form", nil))
+	token := form.Body.String()
+	cookies := form.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token || token == "" {
+		t.Fatalf("GET /form: got token %q and cookies %v", token, cookies)
+	}
+
+	cookie := cookies[0]
+	if cookie.Name != "__Host-csrf" || !cookie.Secure || cookie.Path != "/" || cookie.HttpOnly {
+		t.Fatalf("got cookie %+v, want a Secure, host-only, script-readable cookie for /", cookie)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		status int
+	}{
+		{"matching cookie and header", token, token, http.StatusOK},
+		{"missing cookie", "", token, http.StatusForbidden},
+		{"missing header", token, "", http.StatusForbidden},
+		{"mismatched header", token, "forged", http.StatusForbidden},
+		{"neither cookie nor header", "", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/action", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "__Host-csrf", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestFormLoginRejectsLoginCSRF(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	hashedPassword, err := NewDefaultPasswordEncoder().Encode("attacker password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userStore.SaveUser(&model.User{Username: "attacker", Password: hashedPassword})
+
+	sessionStore := Store.NewInMemorySessionStore()
+	csrfProtection := NewSynchronizerTokenCSRF(sessionStore)
+	router := http.NewServeMux()
+	router.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r)))
+	})
+	router.Handle("POST /login", NewFormLogin(userStore, NewSessionAuth(sessionStore), "/login"))
+	authChain := NewAuthChain(NewSessionAuth(sessionStore)).WithCSRFProtection(csrfProtection)
+	authChain.AddSkipPath("^/login$")
+	handler := AuthMiddleware(authChain, csrfProtection.Protect(router))
+
+	login := func(csrfCookie, csrfToken string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {"attacker"}, "password": {"attacker password"}, "csrf_token": {csrfToken}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: "__Host-csrf", Value: csrfCookie})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// A cross-site form posting the attacker's credentials carries no token
+	if w := login("", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("login without a token: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	page := httptest.NewRecorder()
+	handler.ServeHTTP(page, httptest.NewRequest("GET", "/login", nil))
+	token := page.Body.String()
+	if token == "" {
+		t.Fatal("the login page got no CSRF token")
+	}
+
+	w := login(token, token)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("login with a token: got status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	var sessionCookie *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "__Host-session" {
+			sessionCookie = cookie
+		}
+	}
+	if sessionCookie == nil {
+		t.Fatal("login with a token established no session")
+	}
+}
@@ -0,0 +1,49 @@
+package Auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrCertificateBindingMismatch = errors.New("token is bound to a different client certificate")
+)
+
+// CertificateThumbprint computes the base64url SHA-256 thumbprint of a certificate's DER encoding,
+// the value of the RFC 8705 "x5t#S256" confirmation claim.
+func CertificateThumbprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ObtainClientCertificate returns the client certificate presented during the TLS handshake, if any.
+func ObtainClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return r.TLS.PeerCertificates[0]
+}
+
+// verifyCertificateBinding checks that a certificate-bound token arrives over the connection it is bound to.
+func verifyCertificateBinding(r *http.Request, x5tS256 string) error {
+	if x5tS256 == "" {
+		return nil
+	}
+
+	certificate := ObtainClientCertificate(r)
+	if certificate == nil {
+		return ErrCertificateBindingMismatch
+	}
+
+	thumbprint := CertificateThumbprint(certificate)
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(x5tS256)) != 1 {
+		return ErrCertificateBindingMismatch
+	}
+
+	return nil
+}
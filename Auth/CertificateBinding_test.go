@@ -0,0 +1,211 @@
+package Auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+// newTestCA creates a self-signed certificate authority for client certificates.
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate, key
+}
+
+// newTestClientCertificate issues a client certificate signed by the CA.
+func newTestClientCertificate(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertificateBoundTokensOverMutualTLS(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	boundCertificate := newTestClientCertificate(t, ca, caKey, 2)
+	otherCertificate := newTestClientCertificate(t, ca, caKey, 3)
+
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	jwtAuth := NewJWTAuth(testSecret)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		jwtToken, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore,
+			WithCertificateBinding(ObtainClientCertificate(r)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, TokenResponse{JWTToken: jwtToken, RefreshToken: refreshToken})
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		jwtToken, refreshToken, err := RefreshJWT(r.Header.Get("X-Refresh-Token"), testSecret, refreshTokenStore,
+			WithCertificateBinding(ObtainClientCertificate(r)))
+		if errors.Is(err, ErrCertificateBindingMismatch) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, TokenResponse{JWTToken: jwtToken, RefreshToken: refreshToken})
+	})
+	mux.HandleFunc("/resource", func(w http.ResponseWriter, r *http.Request) {
+		authenticated, err := jwtAuth.Authenticate(w, r)
+		if !authenticated {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	clientWith := func(certificate tls.Certificate) *http.Client {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+		return &http.Client{Transport: transport}
+	}
+	boundClient := clientWith(boundCertificate)
+	otherClient := clientWith(otherCertificate)
+
+	do := func(client *http.Client, path string, header http.Header) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return resp
+	}
+	decodeTokens := func(resp *http.Response) TokenResponse {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("got status %d: %s", resp.StatusCode, body)
+		}
+		var tokens TokenResponse
+		err := json.NewDecoder(resp.Body).Decode(&tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+
+	tokens := decodeTokens(do(boundClient, "/token", http.Header{}))
+
+	claims, err := ParseJWT(tokens.JWTToken, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x509Certificate, _ := x509.ParseCertificate(boundCertificate.Certificate[0])
+	if claims.Confirmation == nil || claims.Confirmation.X5TS256 != CertificateThumbprint(x509Certificate) {
+		t.Fatalf("got confirmation %+v, want the thumbprint of the client certificate", claims.Confirmation)
+	}
+
+	bearer := http.Header{"Authorization": {"Bearer " + tokens.JWTToken}}
+	if resp := do(boundClient, "/resource", bearer); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("bound certificate: got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if resp := do(otherClient, "/resource", bearer); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("other certificate: got status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	refresh := http.Header{"X-Refresh-Token": {tokens.RefreshToken}}
+	if resp := do(otherClient, "/refresh", refresh); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("refresh with other certificate: got status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	refreshed := decodeTokens(do(boundClient, "/refresh", refresh))
+	bearer = http.Header{"Authorization": {"Bearer " + refreshed.JWTToken}}
+	if resp := do(otherClient, "/resource", bearer); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("refreshed token with other certificate: got status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestWithCertificateBindingIgnoresMissingCertificate(t *testing.T) {
+	jwtToken, _, err := GenerateJWT("alice", testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore(),
+		WithCertificateBinding(nil))
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	claims, err := ParseJWT(jwtToken, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Confirmation != nil {
+		t.Fatalf("got confirmation %+v, want an unbound token", claims.Confirmation)
+	}
+}
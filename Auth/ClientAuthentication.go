@@ -0,0 +1,82 @@
+package Auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+var (
+	ErrInvalidClient = errors.New("invalid client credentials")
+
+	errTokenNotOwnedByClient = errors.New("token was not issued to the requesting client")
+)
+
+// ClientAuthenticator authenticates the OAuth client calling a token endpoint such as introspection or revocation.
+type ClientAuthenticator interface {
+	AuthenticateClient(r *http.Request) (string, error) // returns the client ID if successful
+}
+
+// InMemoryClientAuthenticator authenticates confidential clients against a fixed set of client secrets.
+// It accepts both client_secret_basic (HTTP Basic) and client_secret_post (form parameters).
+type InMemoryClientAuthenticator struct {
+	mu      sync.RWMutex
+	clients map[string]string
+}
+
+func NewInMemoryClientAuthenticator() *InMemoryClientAuthenticator {
+	return &InMemoryClientAuthenticator{
+		clients: make(map[string]string),
+	}
+}
+
+func (a *InMemoryClientAuthenticator) AddClient(clientID, clientSecret string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clients[clientID] = clientSecret
+}
+
+func (a *InMemoryClientAuthenticator) AuthenticateClient(r *http.Request) (string, error) {
+	clientID, clientSecret, ok := obtainBasicClientCredentials(r)
+	if !ok {
+		clientID = r.PostFormValue("client_id")
+		clientSecret = r.PostFormValue("client_secret")
+	}
+
+	if clientID == "" {
+		return "", ErrInvalidClient
+	}
+
+	a.mu.RLock()
+	expectedSecret, exists := a.clients[clientID]
+	a.mu.RUnlock()
+
+	if !exists || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(expectedSecret)) != 1 {
+		return "", ErrInvalidClient
+	}
+
+	return clientID, nil
+}
+
+// obtainBasicClientCredentials reads client_secret_basic credentials, which RFC 6749 section 2.3.1 requires to
+// be form-encoded before they are put into the Basic Authorization header.
+func obtainBasicClientCredentials(r *http.Request) (string, string, bool) {
+	encodedID, encodedSecret, ok := r.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+
+	clientID, err := url.QueryUnescape(encodedID)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err := url.QueryUnescape(encodedSecret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, true
+}
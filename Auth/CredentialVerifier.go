@@ -0,0 +1,178 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// CredentialVerifier is the password check shared by every username/password based authentication flow:
+// UsernamePasswordAuth, BasicAuth, FormLogin, LoginHandler and PasswordChanger.
+//
+// Unknown usernames and wrong passwords both yield ErrInvalidCredentials, and for unknown usernames, as well as
+// users whose hash is due for an upgrade, the password is also compared against a dummy hash of the current
+// encoder, so neither the error nor the response time tells an attacker which accounts exist. Hashes stronger
+// than the current encoder's still take longer to check, so raise the encoder's cost rather than lower it.
+type CredentialVerifier struct {
+	userStore       model.UserStore
+	passwordEncoder PasswordEncoder
+	loginThrottle   *LoginThrottle
+	passwordPolicy  *PasswordPolicy
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+func NewCredentialVerifier(userStore model.UserStore) *CredentialVerifier {
+	return &CredentialVerifier{
+		userStore:       userStore,
+		passwordEncoder: NewDefaultPasswordEncoder(),
+	}
+}
+
+// WithPasswordEncoder replaces the default bcrypt-based DelegatingPasswordEncoder.
+func (v *CredentialVerifier) WithPasswordEncoder(passwordEncoder PasswordEncoder) *CredentialVerifier {
+	v.passwordEncoder = passwordEncoder
+	v.dummyHashOnce = sync.Once{} // The dummy hash must come from the encoder in use
+	return v
+}
+
+// WithLoginThrottle rejects logins of usernames and client IPs with too many recent failures.
+func (v *CredentialVerifier) WithLoginThrottle(loginThrottle *LoginThrottle) *CredentialVerifier {
+	v.loginThrottle = loginThrottle
+	return v
+}
+
+// WithPasswordPolicy treats passwords older than the policy's maximum age as expired credentials.
+func (v *CredentialVerifier) WithPasswordPolicy(passwordPolicy *PasswordPolicy) *CredentialVerifier {
+	v.passwordPolicy = passwordPolicy
+	return v
+}
+
+// Verify returns the user if password matches the stored hash. Hashes that the PasswordEncoder considers
+// outdated are transparently replaced by a fresh hash of password through UserStore.UpdatePassword.
+//
+// Users with expired credentials are returned with MustChangePassword set, so every login flow restricts them
+// to changing the password instead of locking them out.
+func (v *CredentialVerifier) Verify(r *http.Request, username, password string) (*model.User, error) {
+	if v.loginThrottle == nil {
+		return v.verify(username, password)
+	}
+
+	clientIP := ObtainClientIP(r)
+
+	// Count the attempt before verifying, so concurrent guesses can't slip past the throttle
+	err := v.loginThrottle.Reserve(username, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := v.verify(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		throttleErr := v.loginThrottle.RecordFailure(username, clientIP)
+		if throttleErr != nil {
+			log.Printf("Failed to record failed login: %v", throttleErr)
+		}
+		return nil, err
+	}
+	if err != nil && !isAccountStatusError(err) {
+		throttleErr := v.loginThrottle.Release(username, clientIP)
+		if throttleErr != nil {
+			log.Printf("Failed to release login attempt: %v", throttleErr)
+		}
+		return nil, err
+	}
+
+	// The password matched, even if the account may not be used
+	throttleErr := v.loginThrottle.RecordSuccess(username, clientIP)
+	if throttleErr != nil {
+		log.Printf("Failed to reset failed logins: %v", throttleErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (v *CredentialVerifier) verify(username, password string) (*model.User, error) {
+	user, err := v.userStore.FindUserByUsername(username)
+
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+		// Spend the same time as for a wrong password of an existing user
+		_, _ = v.passwordEncoder.Matches(password, v.getDummyHash())
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		// Most likely the store is down, but a store that reports unknown users with its own error instead of
+		// model.ErrUserNotFound ends up here too; spend the time anyway so that such users don't stand out
+		_, _ = v.passwordEncoder.Matches(password, v.getDummyHash())
+		return nil, err
+	}
+
+	matches, err := v.passwordEncoder.Matches(password, user.Password)
+	if (err != nil || !matches) && v.passwordEncoder.UpgradeEncoding(user.Password) {
+		// A legacy or cheaper hash is checked faster than the dummy hash of unknown users, which would give
+		// the account away; spend that time as well
+		_, _ = v.passwordEncoder.Matches(password, v.getDummyHash())
+	}
+	if err != nil {
+		// A broken stored hash must not answer differently from a wrong password
+		log.Printf("Failed to match password of user %q: %v", user.Username, err)
+		return nil, ErrInvalidCredentials
+	}
+	if !matches {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Only reveal the account status to someone who knows the password
+	err = checkAccountStatus(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.passwordEncoder.UpgradeEncoding(user.Password) {
+		v.upgradePassword(user, password)
+	}
+
+	if checkCredentialsStatus(user, v.passwordPolicy) != nil {
+		// A copy, so the restriction never leaks into a user the store shares with others
+		restricted := *user
+		restricted.MustChangePassword = true
+		return &restricted, nil
+	}
+
+	return user, nil
+}
+
+// upgradePassword rehashes the password; failing to do so must not fail the login, it is retried next time.
+func (v *CredentialVerifier) upgradePassword(user *model.User, password string) {
+	encodedPassword, err := v.passwordEncoder.Encode(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of user %q: %v", user.Username, err)
+		return
+	}
+
+	err = v.userStore.UpdatePassword(user.Username, encodedPassword)
+	if err != nil {
+		log.Printf("Failed to store rehashed password of user %q: %v", user.Username, err)
+		return
+	}
+
+	user.Password = encodedPassword
+}
+
+func (v *CredentialVerifier) getDummyHash() string {
+	v.dummyHashOnce.Do(func() {
+		dummyHash, err := v.passwordEncoder.Encode("dummy-password-for-timing")
+		if err != nil {
+			log.Printf("Failed to create dummy password hash: %v", err)
+			return
+		}
+		v.dummyHash = dummyHash
+	})
+
+	return v.dummyHash
+}
@@ -0,0 +1,92 @@
+package Auth
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// medianVerifyDuration returns the median time Verify takes to reject a wrong password of username.
+func medianVerifyDuration(t *testing.T, verifier *CredentialVerifier, username string) time.Duration {
+	t.Helper()
+
+	durations := make([]time.Duration, 0, 7)
+	for i := 0; i < cap(durations); i++ {
+		start := time.Now()
+		_, err := verifier.Verify(httptest.NewRequest("POST", "/login", nil), username, "wrong password")
+		durations = append(durations, time.Since(start))
+		if err == nil {
+			t.Fatalf("Verify(%q) accepted a wrong password", username)
+		}
+	}
+
+	slices.Sort(durations)
+	return durations[len(durations)/2]
+}
+
+func TestCredentialVerifierTimingDoesNotRevealAccounts(t *testing.T) {
+	encoder := NewDelegatingPasswordEncoder("bcrypt", map[string]PasswordEncoder{
+		"bcrypt": NewBCryptPasswordEncoder().WithCost(8),
+		"SHA":    NewSHA1PasswordEncoder(),
+	})
+
+	currentHash, err := encoder.Encode("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacySum := sha1.Sum([]byte("secret"))
+
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("current", currentHash)
+	userStore.AddUser("legacy", "{SHA}"+base64.StdEncoding.EncodeToString(legacySum[:]))
+
+	verifier := NewCredentialVerifier(userStore).WithPasswordEncoder(encoder)
+	medianVerifyDuration(t, verifier, "unknown") // Creates the dummy hash
+
+	unknown := medianVerifyDuration(t, verifier, "unknown")
+	for _, username := range []string{"current", "legacy"} {
+		existing := medianVerifyDuration(t, verifier, username)
+		if existing < unknown/2 || existing > unknown*2 {
+			t.Errorf("wrong password of %s took %s, unknown user %s", username, existing, unknown)
+		}
+	}
+}
+
+// customNotFoundUserStore reports unknown users with its own error instead of model.ErrUserNotFound.
+type customNotFoundUserStore struct {
+	model.UserStore
+}
+
+func (s *customNotFoundUserStore) FindUserByUsername(username string) (*model.User, error) {
+	user, err := s.UserStore.FindUserByUsername(username)
+	if errors.Is(err, model.ErrUserNotFound) {
+		return nil, errors.New("user not found")
+	}
+	return user, err
+}
+
+func TestCredentialVerifierTimingWithStoreErrors(t *testing.T) {
+	encoder := NewBCryptPasswordEncoder().WithCost(8)
+	currentHash, err := encoder.Encode("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("current", currentHash)
+
+	verifier := NewCredentialVerifier(&customNotFoundUserStore{userStore}).WithPasswordEncoder(encoder)
+	medianVerifyDuration(t, verifier, "unknown") // Creates the dummy hash
+
+	unknown := medianVerifyDuration(t, verifier, "unknown")
+	existing := medianVerifyDuration(t, verifier, "current")
+	if unknown < existing/2 || unknown > existing*2 {
+		t.Errorf("unknown user reported with a store error took %s, wrong password %s", unknown, existing)
+	}
+}
@@ -0,0 +1,156 @@
+package Auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/golang-jwt/jwt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidDPoPProof     = errors.New("invalid DPoP proof")
+	ErrTokenBindingMismatch = errors.New("token is bound to a different key")
+)
+
+// dpopSigningMethods are the asymmetric algorithms accepted for DPoP proofs; symmetric and "none" are never allowed.
+var dpopSigningMethods = []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+
+// Confirmation is the "cnf" claim (RFC 7800) binding a token to a proof-of-possession key.
+type Confirmation struct {
+	JKT     string `json:"jkt,omitempty"`      // RFC 9449 JWK SHA-256 thumbprint
+	X5TS256 string `json:"x5t#S256,omitempty"` // RFC 8705 client certificate SHA-256 thumbprint
+}
+
+type dpopProofClaims struct {
+	HTM      string `json:"htm"`
+	HTU      string `json:"htu"`
+	ATH      string `json:"ath,omitempty"`
+	ID       string `json:"jti"`
+	IssuedAt int64  `json:"iat"`
+}
+
+// Valid is a no-op, the claims are checked by DPoPVerifier against the request.
+func (c *dpopProofClaims) Valid() error {
+	return nil
+}
+
+// DPoPVerifier verifies DPoP proof JWTs (RFC 9449) sent in the DPoP request header.
+type DPoPVerifier struct {
+	replayCache Store.ReplayCache
+	maxAge      time.Duration
+	clockSkew   time.Duration
+	externalURL *url.URL
+}
+
+func NewDPoPVerifier(replayCache Store.ReplayCache) *DPoPVerifier {
+	return &DPoPVerifier{
+		replayCache: replayCache,
+		maxAge:      5 * time.Minute,
+		clockSkew:   30 * time.Second,
+	}
+}
+
+// WithMaxAge sets how old a proof's iat may be; proofs are remembered in the replay cache for that long.
+func (v *DPoPVerifier) WithMaxAge(maxAge time.Duration) *DPoPVerifier {
+	v.maxAge = maxAge
+	return v
+}
+
+// WithExternalURL sets the public base URL of the server, e.g. "https://api.example.com", which proofs' htu is
+// compared against. It is needed behind a TLS-terminating proxy, where the request URL differs from the client's.
+func (v *DPoPVerifier) WithExternalURL(externalURL *url.URL) *DPoPVerifier {
+	v.externalURL = externalURL
+	return v
+}
+
+// VerifyProof verifies the request's DPoP proof and returns the thumbprint of the key that signed it.
+// Pass the access token the proof must be bound to through ath, or "" at the token endpoint where none exists yet.
+func (v *DPoPVerifier) VerifyProof(r *http.Request, accessToken string) (string, error) {
+	proofs := r.Header.Values("DPoP")
+	if len(proofs) != 1 {
+		return "", fmt.Errorf("%w: expected exactly one DPoP header", ErrInvalidDPoPProof)
+	}
+
+	var proofKey *JWK
+	claims := &dpopProofClaims{}
+	parser := &jwt.Parser{
+		ValidMethods:         dpopSigningMethods,
+		SkipClaimsValidation: true,
+	}
+
+	_, err := parser.ParseWithClaims(proofs[0], claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != "dpop+jwt" {
+			return nil, errors.New("typ must be dpop+jwt")
+		}
+
+		rawKey, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+
+		proofKey = &JWK{}
+		err = json.Unmarshal(rawKey, proofKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return proofKey.PublicKey()
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+
+	if claims.HTM != r.Method {
+		return "", fmt.Errorf("%w: htm does not match request method", ErrInvalidDPoPProof)
+	}
+
+	if !matchesRequestURL(claims.HTU, externalURL(r, v.externalURL)) {
+		return "", fmt.Errorf("%w: htu does not match request URL", ErrInvalidDPoPProof)
+	}
+
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	now := time.Now()
+	if issuedAt.Before(now.Add(-v.maxAge)) || issuedAt.After(now.Add(v.clockSkew)) {
+		return "", fmt.Errorf("%w: iat outside of the accepted window", ErrInvalidDPoPProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if claims.ATH != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", fmt.Errorf("%w: ath does not match access token", ErrInvalidDPoPProof)
+		}
+	}
+
+	if claims.ID == "" {
+		return "", fmt.Errorf("%w: missing jti", ErrInvalidDPoPProof)
+	}
+
+	unused, err := v.replayCache.MarkUsed(claims.ID, issuedAt.Add(v.maxAge+v.clockSkew))
+	if err != nil {
+		return "", err
+	}
+	if !unused {
+		return "", fmt.Errorf("%w: proof has already been used", ErrInvalidDPoPProof)
+	}
+
+	return proofKey.Thumbprint()
+}
+
+// matchesRequestURL compares htu with the request URL, ignoring query and fragment as RFC 9449 requires.
+func matchesRequestURL(htu string, requestURL *url.URL) bool {
+	proofURL, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(proofURL.Scheme, requestURL.Scheme) &&
+		strings.EqualFold(proofURL.Host, requestURL.Host) &&
+		proofURL.Path == requestURL.Path
+}
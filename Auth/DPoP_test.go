@@ -0,0 +1,241 @@
+package Auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testDPoPProof describes a DPoP proof; zero values are filled in by sign.
+type testDPoPProof struct {
+	method      string
+	url         string
+	accessToken string
+	issuedAt    time.Time
+	jti         string
+}
+
+func newTestDPoPKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJWK(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, thumbprint
+}
+
+func (p testDPoPProof) sign(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	if p.issuedAt.IsZero() {
+		p.issuedAt = time.Now()
+	}
+	if p.jti == "" {
+		p.jti = uuid.New().String()
+	}
+
+	claims := jwt.MapClaims{"htm": p.method, "htu": p.url, "iat": p.issuedAt.Unix(), "jti": p.jti}
+	if p.accessToken != "" {
+		sum := sha256.Sum256([]byte(p.accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	jwk, err := NewJWK(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func newDPoPRequest(method, target, proof string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("DPoP", proof)
+	return req
+}
+
+func TestDPoPVerifierVerifyProof(t *testing.T) {
+	key, thumbprint := newTestDPoPKey(t)
+	verifier := NewDPoPVerifier(Store.NewInMemoryReplayCache())
+	const target = "http://example.com/resource"
+
+	valid := testDPoPProof{method: http.MethodGet, url: target + "?ignored=query", accessToken: "access token"}
+	jkt, err := verifier.VerifyProof(newDPoPRequest(http.MethodGet, target, valid.sign(t, key)), "access token")
+	if err != nil {
+		t.Fatalf("valid proof: %v", err)
+	}
+	if jkt != thumbprint {
+		t.Fatalf("got thumbprint %q, want %q", jkt, thumbprint)
+	}
+
+	for name, proof := range map[string]testDPoPProof{
+		"htm mismatch":       {method: http.MethodPost, url: target, accessToken: "access token"},
+		"htu path mismatch":  {method: http.MethodGet, url: "http://example.com/other", accessToken: "access token"},
+		"htu host mismatch":  {method: http.MethodGet, url: "http://evil.com/resource", accessToken: "access token"},
+		"htu scheme":         {method: http.MethodGet, url: "https://example.com/resource", accessToken: "access token"},
+		"stale iat":          {method: http.MethodGet, url: target, accessToken: "access token", issuedAt: time.Now().Add(-10 * time.Minute)},
+		"iat in the future":  {method: http.MethodGet, url: target, accessToken: "access token", issuedAt: time.Now().Add(time.Minute)},
+		"wrong ath":          {method: http.MethodGet, url: target, accessToken: "other access token"},
+		"missing ath":        {method: http.MethodGet, url: target},
+		"htu without scheme": {method: http.MethodGet, url: "example.com/resource", accessToken: "access token"},
+	} {
+		_, err := verifier.VerifyProof(newDPoPRequest(http.MethodGet, target, proof.sign(t, key)), "access token")
+		if !errors.Is(err, ErrInvalidDPoPProof) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidDPoPProof)
+		}
+	}
+}
+
+func TestDPoPVerifierRejectsReplayedProof(t *testing.T) {
+	key, _ := newTestDPoPKey(t)
+	verifier := NewDPoPVerifier(Store.NewInMemoryReplayCache())
+	const target = "http://example.com/token"
+
+	proof := testDPoPProof{method: http.MethodPost, url: target}.sign(t, key)
+	_, err := verifier.VerifyProof(newDPoPRequest(http.MethodPost, target, proof), "")
+	if err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	_, err = verifier.VerifyProof(newDPoPRequest(http.MethodPost, target, proof), "")
+	if !errors.Is(err, ErrInvalidDPoPProof) {
+		t.Fatalf("replay: got %v, want %v", err, ErrInvalidDPoPProof)
+	}
+
+	// A fresh proof with the jti of a used one is a replay as well
+	reused := testDPoPProof{method: http.MethodPost, url: target, jti: "jti-1"}
+	_, err = verifier.VerifyProof(newDPoPRequest(http.MethodPost, target, reused.sign(t, key)), "")
+	if err != nil {
+		t.Fatalf("first use of jti-1: %v", err)
+	}
+	_, err = verifier.VerifyProof(newDPoPRequest(http.MethodPost, target, reused.sign(t, key)), "")
+	if !errors.Is(err, ErrInvalidDPoPProof) {
+		t.Fatalf("reused jti: got %v, want %v", err, ErrInvalidDPoPProof)
+	}
+}
+
+func TestDPoPVerifierRejectsMalformedProofs(t *testing.T) {
+	key, _ := newTestDPoPKey(t)
+	verifier := NewDPoPVerifier(Store.NewInMemoryReplayCache())
+	const target = "http://example.com/token"
+
+	symmetric, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"htm": http.MethodPost, "htu": target, "iat": time.Now().Unix(), "jti": "jti",
+	}).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, _ := newTestDPoPKey(t)
+	proof := testDPoPProof{method: http.MethodPost, url: target}.sign(t, key)
+	forged := proof[:len(proof)-10] + testDPoPProof{method: http.MethodPost, url: target}.sign(t, otherKey)[len(proof)-10:]
+
+	for name, req := range map[string]*http.Request{
+		"no header":         httptest.NewRequest(http.MethodPost, target, nil),
+		"symmetric":         newDPoPRequest(http.MethodPost, target, symmetric),
+		"invalid signature": newDPoPRequest(http.MethodPost, target, forged),
+		"not a JWT":         newDPoPRequest(http.MethodPost, target, "proof"),
+		"two headers": func() *http.Request {
+			req := newDPoPRequest(http.MethodPost, target, proof)
+			req.Header.Add("DPoP", proof)
+			return req
+		}(),
+	} {
+		_, err := verifier.VerifyProof(req, "")
+		if !errors.Is(err, ErrInvalidDPoPProof) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidDPoPProof)
+		}
+	}
+}
+
+func TestJWTAuthWithDPoPBoundToken(t *testing.T) {
+	key, thumbprint := newTestDPoPKey(t)
+	jwtAuth := NewJWTAuth(testSecret).WithDPoP(NewDPoPVerifier(Store.NewInMemoryReplayCache()))
+	const target = "http://example.com/resource"
+
+	accessToken, _, err := GenerateJWT("alice", testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore(),
+		WithDPoPKeyThumbprint(thumbprint))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := func(scheme string, proof string) error {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", scheme+" "+accessToken)
+		if proof != "" {
+			req.Header.Set("DPoP", proof)
+		}
+		_, err := jwtAuth.Authenticate(httptest.NewRecorder(), req)
+		return err
+	}
+
+	err = authenticate("DPoP", testDPoPProof{method: http.MethodGet, url: target, accessToken: accessToken}.sign(t, key))
+	if err != nil {
+		t.Fatalf("valid proof: %v", err)
+	}
+
+	err = authenticate("Bearer", "")
+	if !errors.Is(err, ErrInvalidDPoPProof) {
+		t.Fatalf("as a bearer token: got %v, want %v", err, ErrInvalidDPoPProof)
+	}
+
+	otherKey, _ := newTestDPoPKey(t)
+	err = authenticate("DPoP", testDPoPProof{method: http.MethodGet, url: target, accessToken: accessToken}.sign(t, otherKey))
+	if !errors.Is(err, ErrTokenBindingMismatch) {
+		t.Fatalf("proof of another key: got %v, want %v", err, ErrTokenBindingMismatch)
+	}
+}
+
+func TestJWTCookieSessionRejectsSenderConstrainedTokens(t *testing.T) {
+	_, thumbprint := newTestDPoPKey(t)
+	session := NewJWTCookieSession(testSecret, time.Minute)
+
+	boundToken, _, err := GenerateJWT("alice", testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore(),
+		WithDPoPKeyThumbprint(thumbprint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearerToken, _, err := GenerateJWT("alice", testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := func(token string) (bool, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+		return session.Authenticate(httptest.NewRecorder(), req)
+	}
+
+	if authenticated, err := authenticate(bearerToken); !authenticated {
+		t.Fatalf("bearer token: %v", err)
+	}
+	if authenticated, err := authenticate(boundToken); authenticated || !errors.Is(err, ErrTokenBindingMismatch) {
+		t.Fatalf("DPoP-bound token: got %v, %v, want %v", authenticated, err, ErrTokenBindingMismatch)
+	}
+}
@@ -0,0 +1,175 @@
+package Auth
+
+import (
+	"context"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+)
+
+// emailVerificationAudience marks email verification tokens; access tokens have no audience.
+const emailVerificationAudience = "email_verification"
+
+// EmailVerification proves that users own the email address they registered with, through signed, expiring
+// tokens delivered by a Notifier. Each token is accepted only once.
+type EmailVerification struct {
+	userStore   model.UserStore
+	secret      []byte
+	notifier    Notifier
+	replayCache Store.ReplayCache
+	expiry      time.Duration
+}
+
+// NewEmailVerification creates an EmailVerification. The replay cache remembers used tokens until they expire.
+func NewEmailVerification(userStore model.UserStore, secret []byte, notifier Notifier, replayCache Store.ReplayCache) *EmailVerification {
+	return &EmailVerification{
+		userStore:   userStore,
+		secret:      secret,
+		notifier:    notifier,
+		replayCache: replayCache,
+		expiry:      24 * time.Hour,
+	}
+}
+
+// WithExpiry sets how long a verification token stays valid.
+func (v *EmailVerification) WithExpiry(expiry time.Duration) *EmailVerification {
+	v.expiry = expiry
+	return v
+}
+
+// SendVerification issues a verification token for username and hands it to the Notifier.
+// Users whose email is already verified are skipped.
+func (v *EmailVerification) SendVerification(ctx context.Context, username string) error {
+	user, err := v.userStore.FindUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(v.expiry)
+
+	claims := &Claim{
+		Email: user.Username,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  emailVerificationAudience,
+			Id:        uuid.New().String(), // jti, lets the token be used only once
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+			Subject:   user.Username,
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(v.secret)
+	if err != nil {
+		return err
+	}
+
+	return v.notifier.Notify(ctx, Notification{
+		Kind:      EmailVerificationNotification,
+		Recipient: user.Username,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// Verify checks token and marks the email address of its user as verified.
+func (v *EmailVerification) Verify(token string) error {
+	claims, err := parseJWTClaims(token, v.secret)
+	if err != nil || claims.Audience != emailVerificationAudience || claims.Id == "" {
+		return ErrInvalidVerificationToken
+	}
+
+	unused, err := v.replayCache.MarkUsed(claims.Id, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := v.userStore.FindUserByUsername(claims.Subject)
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	// The username may have changed to another address since the token was issued
+	if user.Username != claims.Email {
+		return ErrInvalidVerificationToken
+	}
+
+	return v.userStore.MarkEmailVerified(user.Username)
+}
+
+// ************************************************************************ //
+
+type EmailVerificationRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyHandler confirms an email address with the token from a verification notification.
+func (v *EmailVerification) VerifyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var verificationReq EmailVerificationRequest
+		if !decodeJSONRequest(w, r, &verificationReq) {
+			return
+		}
+
+		err := v.Verify(verificationReq.Token)
+		if errors.Is(err, ErrInvalidVerificationToken) {
+			writeError(w, http.StatusBadRequest, "invalid_token", "Invalid or expired email verification token")
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to verify email: %v", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "Failed to verify email")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ResendHandler sends a new verification token to the authenticated user.
+func (v *EmailVerification) ResendHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST is allowed")
+			return
+		}
+
+		principal := PrincipalFromRequest(r)
+		if principal == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+			return
+		}
+
+		err := v.SendVerification(r.Context(), principal.Subject)
+		if err != nil {
+			log.Printf("Failed to send email verification: %v", err)
+			writeError(w, http.StatusInternalServerError, "server_error", "Failed to send email verification")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,159 @@
+package Auth
+
+import (
+	"encoding/base64"
+	"github.com/ayushs-2k4/go-security/model"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SessionEstablisher keeps a user authenticated across requests once a form login succeeds.
+type SessionEstablisher interface {
+	EstablishSession(w http.ResponseWriter, r *http.Request, user *model.User) error
+}
+
+// FormLogin implements classic browser form login on top of AuthChain.
+//
+// As the chain's EntryPoint it redirects unauthenticated browser requests to the login page, remembering
+// the requested URL in a cookie. Served as an http.Handler it processes the POSTed login form and sends
+// the user back to that URL.
+type FormLogin struct {
+	credentialVerifier *CredentialVerifier
+	sessionEstablisher SessionEstablisher
+	loginPage          string
+	defaultTargetURL   string
+	savedRequestCookie string
+	secureCookie       bool
+}
+
+func NewFormLogin(userStore model.UserStore, sessionEstablisher SessionEstablisher, loginPage string) *FormLogin {
+	return &FormLogin{
+		credentialVerifier: NewCredentialVerifier(userStore),
+		sessionEstablisher: sessionEstablisher,
+		loginPage:          loginPage,
+		defaultTargetURL:   "/",
+		savedRequestCookie: "saved_request",
+	}
+}
+
+// WithCredentialVerifier replaces the verifier, e.g. to use a different PasswordEncoder.
+func (f *FormLogin) WithCredentialVerifier(credentialVerifier *CredentialVerifier) *FormLogin {
+	f.credentialVerifier = credentialVerifier
+	return f
+}
+
+// WithDefaultTargetURL sets where users land after logging in when no request was saved.
+func (f *FormLogin) WithDefaultTargetURL(defaultTargetURL string) *FormLogin {
+	f.defaultTargetURL = defaultTargetURL
+	return f
+}
+
+// WithSecureCookie always marks the saved-request cookie Secure, not only on requests received over TLS.
+// Use it behind a TLS-terminating proxy.
+func (f *FormLogin) WithSecureCookie() *FormLogin {
+	f.secureCookie = true
+	return f
+}
+
+// Commence redirects browser navigations to the login page and answers everything else with 401.
+func (f *FormLogin) Commence(w http.ResponseWriter, r *http.Request, err error) {
+	if !isBrowserNavigation(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     f.savedRequestCookie,
+		Value:    base64.RawURLEncoding.EncodeToString([]byte(r.URL.RequestURI())),
+		Path:     "/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   f.secureCookie || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, f.loginPage, http.StatusFound)
+}
+
+// ServeHTTP processes the login form. The login processing path must be added to the AuthChain skip paths, so
+// wrap FormLogin and the login page with CSRFProtection.Protect to keep login CSRF out.
+func (f *FormLogin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+
+	user, err := f.credentialVerifier.Verify(r, username, password)
+	if err != nil {
+		http.Redirect(w, r, f.loginPage+"?error", http.StatusSeeOther)
+		return
+	}
+
+	err = f.sessionEstablisher.EstablishSession(w, r, user)
+	if err != nil {
+		log.Printf("Failed to establish session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, f.targetURL(w, r), http.StatusSeeOther)
+}
+
+// targetURL returns the saved request URL, consuming the cookie, or the default target URL.
+func (f *FormLogin) targetURL(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(f.savedRequestCookie)
+	if err != nil {
+		return f.defaultTargetURL
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     f.savedRequestCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   f.secureCookie || r.TLS != nil,
+	})
+
+	savedURL, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || !isLocalRedirect(string(savedURL)) {
+		return f.defaultTargetURL
+	}
+
+	return string(savedURL)
+}
+
+// isLocalRedirect guards against open redirects: only paths on this host are allowed.
+func isLocalRedirect(target string) bool {
+	// "//host" and "/\host" are treated as absolute URLs by browsers
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return false
+	}
+
+	if strings.ContainsAny(target, "\r\n\t") {
+		return false
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return false
+	}
+
+	return true
+}
+
+// isBrowserNavigation reports whether a request is a top-level browser page load that can be redirected.
+func isBrowserNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,178 @@
+package Auth
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsLocalRedirect(t *testing.T) {
+	tests := []struct {
+		target string
+		local  bool
+	}{
+		{"/", true},
+		{"/account/settings?tab=security#password", true},
+		{"/a//b", true},
+		{"/%2F%2Fevil.com", true}, // An escaped slash stays part of the path
+		{"/%5Cevil.com", true},
+		{"", false},
+		{"account", false},
+		{"evil.com", false},
+		{"//evil.com", false},
+		{"//evil.com/path", false},
+		{"///evil.com", false},
+		{"/\\evil.com", false},
+		{"/\\/evil.com", false},
+		{"\\\\evil.com", false},
+		{"https://evil.com", false},
+		{"HTTPS://evil.com", false},
+		{"http:/evil.com", false},
+		{"javascript:alert(1)", false},
+		{"%2F%2Fevil.com", false},
+		{"/\t/evil.com", false},
+		{"/\r\nLocation: https://evil.com", false},
+		{"/\n/evil.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := isLocalRedirect(tt.target); got != tt.local {
+			t.Errorf("isLocalRedirect(%q) = %v, want %v", tt.target, got, tt.local)
+		}
+	}
+}
+
+func newTestFormLogin(t *testing.T) *FormLogin {
+	t.Helper()
+
+	userStore := Store.NewInMemoryUserStore()
+	hashedPassword, err := NewDefaultPasswordEncoder().Encode("alice password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userStore.SaveUser(&model.User{Username: "alice", Password: hashedPassword})
+
+	return NewFormLogin(userStore, NewSessionAuth(Store.NewInMemorySessionStore()), "/login")
+}
+
+func postLoginForm(formLogin *FormLogin, password string, savedRequest string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {"alice"}, "password": {password}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if savedRequest != "" {
+		req.AddCookie(&http.Cookie{Name: "saved_request", Value: base64.RawURLEncoding.EncodeToString([]byte(savedRequest))})
+	}
+
+	w := httptest.NewRecorder()
+	formLogin.ServeHTTP(w, req)
+	return w
+}
+
+func TestFormLoginRedirectsToSavedRequest(t *testing.T) {
+	formLogin := newTestFormLogin(t).WithDefaultTargetURL("/home")
+
+	tests := []struct {
+		savedRequest string
+		location     string
+	}{
+		{"", "/home"},
+		{"/account?tab=security", "/account?tab=security"},
+		{"//evil.com", "/home"},
+		{"/\\evil.com", "/home"},
+		{"https://evil.com", "/home"},
+	}
+
+	for _, tt := range tests {
+		w := postLoginForm(formLogin, "alice password", tt.savedRequest)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != tt.location {
+			t.Errorf("saved request %q: got status %d to %q, want %d to %q",
+				tt.savedRequest, w.Code, w.Header().Get("Location"), http.StatusSeeOther, tt.location)
+		}
+	}
+
+	// A malformed cookie falls back to the default as well
+	form := url.Values{"username": {"alice"}, "password": {"alice password"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "saved_request", Value: "not base64!"})
+	w := httptest.NewRecorder()
+	formLogin.ServeHTTP(w, req)
+	if location := w.Header().Get("Location"); location != "/home" {
+		t.Fatalf("malformed saved request: got redirect to %q, want %q", location, "/home")
+	}
+}
+
+func TestFormLoginRejectsWrongPassword(t *testing.T) {
+	w := postLoginForm(newTestFormLogin(t), "wrong password", "/account")
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?error" {
+		t.Fatalf("got status %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), http.StatusSeeOther, "/login?error")
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "__Host-session" {
+			t.Fatal("a session was established for a wrong password")
+		}
+	}
+}
+
+func TestFormLoginCommence(t *testing.T) {
+	tests := []struct {
+		name             string
+		withSecureCookie bool
+		tls              bool
+		secure           bool
+	}{
+		{"plain HTTP", false, false, false},
+		{"direct TLS", false, true, true},
+		{"behind a TLS-terminating proxy", true, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formLogin := newTestFormLogin(t)
+			if tt.withSecureCookie {
+				formLogin.WithSecureCookie()
+			}
+
+			req := httptest.NewRequest("GET", "/account?tab=security", nil)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml")
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+			formLogin.Commence(w, req, nil)
+
+			if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+				t.Fatalf("got status %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), http.StatusFound, "/login")
+			}
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("got cookies %v, want the saved request", cookies)
+			}
+			cookie := cookies[0]
+			if cookie.Secure != tt.secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("got cookie %+v, want Secure %v, HttpOnly and SameSite=Lax", cookie, tt.secure)
+			}
+
+			savedRequest, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+			if err != nil || string(savedRequest) != "/account?tab=security" {
+				t.Fatalf("got saved request %q (%v)", savedRequest, err)
+			}
+		})
+	}
+
+	// API clients get a plain 401 instead of a redirect
+	req := httptest.NewRequest("POST", "/account", nil)
+	w := httptest.NewRecorder()
+	newTestFormLogin(t).Commence(w, req, nil)
+	if w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("got status %d and cookies %v, want %d and none", w.Code, w.Result().Cookies(), http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,164 @@
+package Auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// IntrospectionResponse is the token introspection response defined by RFC 7662 section 2.2.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+}
+
+// Introspector resolves a token into its introspection response.
+// An unknown, expired or revoked token yields an inactive response, not an error.
+type Introspector interface {
+	Introspect(ctx context.Context, token string) (*IntrospectionResponse, error)
+}
+
+// StoreIntrospector introspects opaque tokens issued by GenerateOpaqueToken.
+type StoreIntrospector struct {
+	accessTokenStore Store.AccessTokenStore
+}
+
+func NewStoreIntrospector(accessTokenStore Store.AccessTokenStore) *StoreIntrospector {
+	return &StoreIntrospector{
+		accessTokenStore: accessTokenStore,
+	}
+}
+
+func (i *StoreIntrospector) Introspect(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	record, err := ValidateOpaqueToken(token, i.accessTokenStore)
+	if err != nil {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     record.Scope,
+		ClientID:  record.ClientID,
+		Username:  record.Subject,
+		TokenType: "Bearer",
+		ExpiresAt: record.ExpiresAt.Unix(),
+		IssuedAt:  record.IssuedAt.Unix(),
+		Subject:   record.Subject,
+	}, nil
+}
+
+// RemoteIntrospector calls an RFC 7662 introspection endpoint, authenticating with client_secret_basic.
+type RemoteIntrospector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+}
+
+func NewRemoteIntrospector(endpoint, clientID, clientSecret string) *RemoteIntrospector {
+	return &RemoteIntrospector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used to reach the introspection endpoint.
+func (i *RemoteIntrospector) WithHTTPClient(httpClient *http.Client) *RemoteIntrospector {
+	i.httpClient = httpClient
+	return i
+}
+
+func (i *RemoteIntrospector) Introspect(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned status %d", resp.StatusCode)
+	}
+
+	var introspection IntrospectionResponse
+	err = json.NewDecoder(resp.Body).Decode(&introspection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &introspection, nil
+}
+
+// ************************************************************************ //
+
+// IntrospectionHandler serves the RFC 7662 token introspection endpoint.
+type IntrospectionHandler struct {
+	clientAuthenticator ClientAuthenticator
+	introspector        Introspector
+}
+
+func NewIntrospectionHandler(clientAuthenticator ClientAuthenticator, introspector Introspector) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		clientAuthenticator: clientAuthenticator,
+		introspector:        introspector,
+	}
+}
+
+func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "introspection requires POST")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	// The endpoint must not become a token scanning oracle, so callers have to authenticate
+	_, err = h.clientAuthenticator.AuthenticateClient(r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "missing token parameter")
+		return
+	}
+
+	introspection, err := h.introspector.Introspect(r.Context(), token)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, introspection)
+}
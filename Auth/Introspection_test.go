@@ -0,0 +1,39 @@
+package Auth
+
+import (
+	"context"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemoteIntrospectorAuthenticatesWithEncodedSecret(t *testing.T) {
+	const clientID = "resource server:1"
+	const clientSecret = "a+b/c=d%e f"
+
+	clientAuthenticator := NewInMemoryClientAuthenticator()
+	clientAuthenticator.AddClient(clientID, clientSecret)
+
+	accessTokenStore := Store.NewInMemoryAccessTokenStore()
+	server := httptest.NewServer(NewIntrospectionHandler(clientAuthenticator, NewStoreIntrospector(accessTokenStore)))
+	defer server.Close()
+
+	accessToken, _, err := GenerateOpaqueToken("alice", time.Minute, accessTokenStore, Store.NewInMemoryRefreshTokenStore())
+	if err != nil {
+		t.Fatalf("GenerateOpaqueToken: %v", err)
+	}
+
+	introspection, err := NewRemoteIntrospector(server.URL, clientID, clientSecret).Introspect(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("Introspect: %v", err)
+	}
+	if !introspection.Active || introspection.Subject != "alice" {
+		t.Fatalf("got %+v, want an active token of alice", introspection)
+	}
+
+	_, err = NewRemoteIntrospector(server.URL, clientID, "a b/c=d%e f").Introspect(context.Background(), accessToken)
+	if err == nil {
+		t.Fatal("Introspect with a wrong secret succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package Auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"time"
+)
+
+// JWTCookieSession is a stateless session kept in an HttpOnly cookie holding a JWT.
+// It establishes the session for FormLogin and authenticates the following requests as an AuthMethod.
+type JWTCookieSession struct {
+	secret     []byte
+	expiry     time.Duration
+	cookieName string
+	denylist   Store.TokenDenylist
+}
+
+func NewJWTCookieSession(secret []byte, expiry time.Duration) *JWTCookieSession {
+	return &JWTCookieSession{
+		secret:     secret,
+		expiry:     expiry,
+		cookieName: "access_token",
+	}
+}
+
+// WithDenylist rejects cookies whose JWT has been revoked, e.g. with RevokeJWT on logout.
+func (s *JWTCookieSession) WithDenylist(denylist Store.TokenDenylist) *JWTCookieSession {
+	s.denylist = denylist
+	return s
+}
+
+func (s *JWTCookieSession) EstablishSession(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	var tokenString string
+	var err error
+	if user.MustChangePassword {
+		tokenString, err = GeneratePasswordChangeJWT(user.Username, s.secret, s.expiry)
+	} else {
+		tokenString, err = generateJWTOnly(user.Username, s.secret, s.expiry, newTokenOptions(nil))
+	}
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    tokenString,
+		Path:     "/",
+		MaxAge:   int(s.expiry.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (s *JWTCookieSession) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
+	cookie, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return false, errors.New("missing session cookie")
+	}
+
+	claims, err := parseAccessToken(cookie.Value, s.secret)
+	if err != nil {
+		return false, err
+	}
+
+	err = checkDenylist(s.denylist, claims)
+	if err != nil {
+		return false, err
+	}
+
+	// A cookie can't carry a DPoP proof or prove the client certificate a token was bound to elsewhere, and
+	// sessions never issue bound tokens, so a bound token in the cookie must have been stolen
+	if claims.Confirmation != nil {
+		return false, fmt.Errorf("%w: sender-constrained token in session cookie", ErrTokenBindingMismatch)
+	}
+
+	setPrincipal(r, &Principal{
+		Subject:            claims.Subject,
+		PasswordChangeOnly: hasScope(claims.Scope, PasswordChangeScope),
+		IssuedAt:           time.Unix(claims.IssuedAt, 0),
+	})
+
+	return true, nil
+}
@@ -0,0 +1,378 @@
+package Auth
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEncodingNotSupported = errors.New("password encoder only verifies legacy hashes")
+)
+
+// The encoders in this file exist to verify hashes imported from other systems. None of them is fit to
+// produce new hashes, so they all report UpgradeEncoding, letting CredentialVerifier replace the hash with
+// the default format on the user's next successful login.
+
+// FormatDetectingPasswordEncoder matches hashes without a {id} prefix by recognizing their format:
+// bcrypt ($2a$, $2b$, $2y$), PHC argon2 ($argon2id$, $argon2i$), scrypt, PBKDF2, htpasswd APR1-MD5 ($apr1$)
+// and Django PBKDF2 (pbkdf2_sha256$, pbkdf2_sha1$).
+type FormatDetectingPasswordEncoder struct {
+	formats []detectedFormat
+}
+
+type detectedFormat struct {
+	prefix  string
+	encoder PasswordEncoder
+}
+
+func NewFormatDetectingPasswordEncoder() *FormatDetectingPasswordEncoder {
+	bcryptEncoder := NewBCryptPasswordEncoder()
+	argon2Encoder := NewArgon2PasswordEncoder()
+	djangoEncoder := NewDjangoPBKDF2PasswordEncoder()
+
+	return &FormatDetectingPasswordEncoder{
+		formats: []detectedFormat{
+			{"$2a$", bcryptEncoder},
+			{"$2b$", bcryptEncoder},
+			{"$2y$", bcryptEncoder},
+			{"$argon2id$", argon2Encoder},
+			{"$argon2i$", argon2Encoder},
+			{"$scrypt$", NewSCryptPasswordEncoder()},
+			{"$pbkdf2-sha256$", NewPBKDF2PasswordEncoder()},
+			{"$apr1$", NewAPR1PasswordEncoder()},
+			{"pbkdf2_sha256$", djangoEncoder},
+			{"pbkdf2_sha1$", djangoEncoder},
+		},
+	}
+}
+
+func (e *FormatDetectingPasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *FormatDetectingPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	for _, format := range e.formats {
+		if strings.HasPrefix(encodedPassword, format.prefix) {
+			return format.encoder.Matches(rawPassword, encodedPassword)
+		}
+	}
+
+	return false, fmt.Errorf("%w: unrecognized hash format", ErrMalformedPasswordHash)
+}
+
+func (e *FormatDetectingPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// ************************************************************************ //
+
+// DjangoPBKDF2PasswordEncoder verifies Django's pbkdf2_sha256$<iterations>$<salt>$<hash> and pbkdf2_sha1 hashes.
+type DjangoPBKDF2PasswordEncoder struct{}
+
+func NewDjangoPBKDF2PasswordEncoder() *DjangoPBKDF2PasswordEncoder {
+	return &DjangoPBKDF2PasswordEncoder{}
+}
+
+func (e *DjangoPBKDF2PasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *DjangoPBKDF2PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	parts := strings.Split(encodedPassword, "$")
+	if len(parts) != 4 {
+		return false, ErrMalformedPasswordHash
+	}
+
+	var hashFunc func() hash.Hash
+	switch parts[0] {
+	case "pbkdf2_sha256":
+		hashFunc = sha256.New
+	case "pbkdf2_sha1":
+		hashFunc = sha1.New
+	default:
+		return false, fmt.Errorf("%w: unsupported Django algorithm %s", ErrMalformedPasswordHash, parts[0])
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, ErrMalformedPasswordHash
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false, ErrMalformedPasswordHash
+	}
+
+	// Django uses the salt string itself, not a decoded form of it
+	actual := pbkdf2.Key([]byte(rawPassword), []byte(parts[2]), iterations, len(expected), hashFunc)
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
+
+func (e *DjangoPBKDF2PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// ************************************************************************ //
+
+// SpringPBKDF2PasswordEncoder verifies hashes of Spring Security's Pbkdf2PasswordEncoder: the hex encoded salt
+// followed by the derived key, with the algorithm and iteration count fixed by configuration rather than stored.
+type SpringPBKDF2PasswordEncoder struct {
+	hashFunc   func() hash.Hash
+	iterations int
+	saltLength int
+}
+
+// NewSpringPBKDF2PasswordEncoder matches Spring's {pbkdf2} hashes, produced with its v5.5 defaults of
+// PBKDF2-HMAC-SHA1, 185,000 iterations and an 8 byte salt.
+func NewSpringPBKDF2PasswordEncoder() *SpringPBKDF2PasswordEncoder {
+	return &SpringPBKDF2PasswordEncoder{
+		hashFunc:   sha1.New,
+		iterations: 185_000,
+		saltLength: 8,
+	}
+}
+
+// NewSpringV58PBKDF2PasswordEncoder matches Spring's {pbkdf2@SpringSecurity_v5_8} hashes, produced with
+// PBKDF2-HMAC-SHA256, 310,000 iterations and a 16 byte salt.
+func NewSpringV58PBKDF2PasswordEncoder() *SpringPBKDF2PasswordEncoder {
+	return &SpringPBKDF2PasswordEncoder{
+		hashFunc:   sha256.New,
+		iterations: 310_000,
+		saltLength: 16,
+	}
+}
+
+func (e *SpringPBKDF2PasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *SpringPBKDF2PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	decoded, err := hex.DecodeString(encodedPassword)
+	if err != nil || len(decoded) <= e.saltLength {
+		return false, ErrMalformedPasswordHash
+	}
+
+	salt, expected := decoded[:e.saltLength], decoded[e.saltLength:]
+	actual := pbkdf2.Key([]byte(rawPassword), salt, e.iterations, len(expected), e.hashFunc)
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
+
+func (e *SpringPBKDF2PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// ************************************************************************ //
+
+// SpringSCryptPasswordEncoder verifies hashes of Spring Security's SCryptPasswordEncoder, stored as
+// $<hex of log2(N)<<16 | r<<8 | p>$<base64 salt>$<base64 key>.
+type SpringSCryptPasswordEncoder struct{}
+
+func NewSpringSCryptPasswordEncoder() *SpringSCryptPasswordEncoder {
+	return &SpringSCryptPasswordEncoder{}
+}
+
+func (e *SpringSCryptPasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *SpringSCryptPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	parts := strings.Split(encodedPassword, "$")
+	if len(parts) != 4 || parts[0] != "" {
+		return false, ErrMalformedPasswordHash
+	}
+
+	params, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return false, ErrMalformedPasswordHash
+	}
+	logN, r, p := int(params>>16&0xffff), int(params>>8&0xff), int(params&0xff)
+	if logN == 0 || logN > 30 || r == 0 || p == 0 {
+		return false, fmt.Errorf("%w: invalid scrypt parameters", ErrMalformedPasswordHash)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrMalformedPasswordHash
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false, ErrMalformedPasswordHash
+	}
+
+	actual, err := scrypt.Key([]byte(rawPassword), salt, 1<<logN, r, p, len(expected))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
+
+func (e *SpringSCryptPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// ************************************************************************ //
+
+// APR1PasswordEncoder verifies Apache htpasswd $apr1$ (MD5-based crypt) hashes.
+type APR1PasswordEncoder struct{}
+
+func NewAPR1PasswordEncoder() *APR1PasswordEncoder {
+	return &APR1PasswordEncoder{}
+}
+
+func (e *APR1PasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *APR1PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	parts := strings.Split(encodedPassword, "$")
+	if len(parts) != 4 || parts[0] != "" || parts[1] != "apr1" {
+		return false, ErrMalformedPasswordHash
+	}
+
+	actual := apr1Crypt([]byte(rawPassword), []byte(parts[2]))
+
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(encodedPassword)) == 1, nil
+}
+
+func (e *APR1PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// apr1Crypt implements Apache's variant of the FreeBSD MD5 crypt algorithm.
+func apr1Crypt(password, salt []byte) string {
+	const magic = "$apr1$"
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	alternate := md5.New()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	alternateSum := alternate.Sum(nil)
+
+	digest := md5.New()
+	digest.Write(password)
+	digest.Write([]byte(magic))
+	digest.Write(salt)
+	for i := len(password); i > 0; i -= 16 {
+		digest.Write(alternateSum[:min(i, 16)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			digest.Write([]byte{0})
+		} else {
+			digest.Write(password[:1])
+		}
+	}
+	final := digest.Sum(nil)
+
+	// 1000 rounds to slow down brute force, as weak as that is by today's standards
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(password)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(password)
+		}
+		final = round.Sum(nil)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(magic)
+	sb.Write(salt)
+	sb.WriteString("$")
+
+	to64 := func(value uint32, n int) {
+		for ; n > 0; n-- {
+			sb.WriteByte(itoa64[value&0x3f])
+			value >>= 6
+		}
+	}
+	to64(uint32(final[0])<<16|uint32(final[6])<<8|uint32(final[12]), 4)
+	to64(uint32(final[1])<<16|uint32(final[7])<<8|uint32(final[13]), 4)
+	to64(uint32(final[2])<<16|uint32(final[8])<<8|uint32(final[14]), 4)
+	to64(uint32(final[3])<<16|uint32(final[9])<<8|uint32(final[15]), 4)
+	to64(uint32(final[4])<<16|uint32(final[10])<<8|uint32(final[5]), 4)
+	to64(uint32(final[11]), 2)
+
+	return sb.String()
+}
+
+// ************************************************************************ //
+
+// SHA1PasswordEncoder verifies unsalted htpasswd {SHA} hashes. DelegatingPasswordEncoder.WithLegacyEncoders
+// registers it under the id "SHA", so the {SHA} prefix is stripped before it is called.
+type SHA1PasswordEncoder struct{}
+
+func NewSHA1PasswordEncoder() *SHA1PasswordEncoder {
+	return &SHA1PasswordEncoder{}
+}
+
+func (e *SHA1PasswordEncoder) Encode(rawPassword string) (string, error) {
+	return "", ErrEncodingNotSupported
+}
+
+func (e *SHA1PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	expected, err := base64.StdEncoding.DecodeString(encodedPassword)
+	if err != nil || len(expected) != sha1.Size {
+		return false, ErrMalformedPasswordHash
+	}
+
+	actual := sha1.Sum([]byte(rawPassword))
+
+	return subtle.ConstantTimeCompare(actual[:], expected) == 1, nil
+}
+
+func (e *SHA1PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
+
+// ************************************************************************ //
+
+// NoOpPasswordEncoder compares plain text passwords, as stored by Spring Security under {noop}.
+// DelegatingPasswordEncoder.WithLegacyEncoders registers it.
+type NoOpPasswordEncoder struct{}
+
+func NewNoOpPasswordEncoder() *NoOpPasswordEncoder {
+	return &NoOpPasswordEncoder{}
+}
+
+func (e *NoOpPasswordEncoder) Encode(rawPassword string) (string, error) {
+	return rawPassword, nil
+}
+
+func (e *NoOpPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(rawPassword), []byte(encodedPassword)) == 1, nil
+}
+
+func (e *NoOpPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	return true
+}
@@ -0,0 +1,247 @@
+package Auth
+
+import (
+	"fmt"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoginThrottledError is returned while a username or client IP must wait before trying to log in again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // true for a lockout, false for a progressive delay
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// setRetryAfter writes the Retry-After header in whole seconds, rounded up.
+func (e *LoginThrottledError) setRetryAfter(w http.ResponseWriter) {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+}
+
+// LoginThrottle slows down and eventually locks out brute-force attacks, tracking failures both per
+// username and per client IP. Every failure doubles the wait before the next attempt, starting at baseDelay
+// and capped at maxDelay; reaching a failure threshold locks the key for lockoutDuration. Failures older than
+// failureWindow are forgotten.
+type LoginThrottle struct {
+	store                  Store.LoginAttemptStore
+	maxFailuresPerUsername int
+	maxFailuresPerIP       int
+	lockoutDuration        time.Duration
+	baseDelay              time.Duration
+	maxDelay               time.Duration
+	failureWindow          time.Duration
+}
+
+func NewLoginThrottle(store Store.LoginAttemptStore) *LoginThrottle {
+	return &LoginThrottle{
+		store:                  store,
+		maxFailuresPerUsername: 5,
+		maxFailuresPerIP:       50,
+		lockoutDuration:        15 * time.Minute,
+		baseDelay:              time.Second,
+		maxDelay:               30 * time.Second,
+		failureWindow:          15 * time.Minute,
+	}
+}
+
+// WithThresholds sets after how many failures a username or client IP is locked out, and for how long.
+func (t *LoginThrottle) WithThresholds(maxFailuresPerUsername, maxFailuresPerIP int, lockoutDuration time.Duration) *LoginThrottle {
+	t.maxFailuresPerUsername = maxFailuresPerUsername
+	t.maxFailuresPerIP = maxFailuresPerIP
+	t.lockoutDuration = lockoutDuration
+	return t
+}
+
+// WithDelays sets the progressive delay; a baseDelay of 0 disables it.
+func (t *LoginThrottle) WithDelays(baseDelay, maxDelay time.Duration) *LoginThrottle {
+	t.baseDelay = baseDelay
+	t.maxDelay = maxDelay
+	return t
+}
+
+// WithFailureWindow sets how long failures are remembered.
+func (t *LoginThrottle) WithFailureWindow(failureWindow time.Duration) *LoginThrottle {
+	t.failureWindow = failureWindow
+	return t
+}
+
+// Reserve counts a login attempt of username from clientIP as failed before its password is verified, so that
+// concurrent guesses can't all get past the throttle before any of them is recorded. It returns a
+// *LoginThrottledError if the username or client IP may not attempt to log in right now. Every successful
+// Reserve must be followed by RecordFailure, RecordSuccess or Release; a rejected one takes back what it counted.
+func (t *LoginThrottle) Reserve(username, clientIP string) error {
+	now := time.Now()
+
+	reserved := make([]string, 0, 2)
+	for _, key := range t.keys(username, clientIP) {
+		recorded, err := t.reserveKey(key, now)
+		if recorded {
+			reserved = append(reserved, key)
+		}
+		if err != nil {
+			// Give back what this rejected attempt counted, or a throttled client IP would keep raising the
+			// failures of every username it tries
+			releaseErr := t.releaseKeys(reserved)
+			if releaseErr != nil {
+				return releaseErr
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveKey counts an attempt against key, reporting whether a failure was recorded even if the attempt is
+// rejected.
+func (t *LoginThrottle) reserveKey(key string, now time.Time) (bool, error) {
+	previous, err := t.store.Find(key)
+	if err != nil {
+		return false, err
+	}
+
+	if now.Before(previous.LockedUntil) {
+		return false, &LoginThrottledError{RetryAfter: previous.LockedUntil.Sub(now), Locked: true}
+	}
+
+	// Start counting afresh once the earlier failures are outside the window
+	if previous.Failures > 0 && now.Sub(previous.LastFailure) > t.failureWindow {
+		err := t.store.Reset(key)
+		if err != nil {
+			return false, err
+		}
+		previous = Store.LoginAttempts{}
+	}
+
+	if previous.Failures > 0 {
+		nextAttempt := previous.LastFailure.Add(t.delay(previous.Failures))
+		if now.Before(nextAttempt) {
+			return false, &LoginThrottledError{RetryAfter: nextAttempt.Sub(now)}
+		}
+	}
+
+	attempts, err := t.store.RecordFailure(key, now)
+	if err != nil {
+		return false, err
+	}
+
+	// Attempts that got in between Find and RecordFailure are in flight and count against this one
+	if maxFailures := t.maxFailures(key); maxFailures > 0 && attempts.Failures > maxFailures {
+		return true, &LoginThrottledError{RetryAfter: t.lockoutDuration, Locked: true}
+	}
+	if t.baseDelay > 0 && attempts.Failures > previous.Failures+1 {
+		return true, &LoginThrottledError{RetryAfter: t.delay(attempts.Failures - 1)}
+	}
+
+	return true, nil
+}
+
+// RecordFailure completes a reserved attempt whose password was wrong, locking out keys that reached their
+// threshold.
+func (t *LoginThrottle) RecordFailure(username, clientIP string) error {
+	now := time.Now()
+
+	for _, key := range t.keys(username, clientIP) {
+		attempts, err := t.store.Find(key)
+		if err != nil {
+			return err
+		}
+
+		if maxFailures := t.maxFailures(key); maxFailures > 0 && attempts.Failures >= maxFailures {
+			err := t.store.Lock(key, now.Add(t.lockoutDuration))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess completes a reserved attempt whose password matched. It clears the failures of the username,
+// while the client IP only gets the reserved attempt back and keeps its history, otherwise an attacker could
+// reset it by logging into an account of their own between guesses.
+func (t *LoginThrottle) RecordSuccess(username, clientIP string) error {
+	err := t.store.Reset(usernameKey(username))
+	if err != nil {
+		return err
+	}
+
+	if clientIP == "" {
+		return nil
+	}
+	return t.store.RemoveFailure(ipKey(clientIP))
+}
+
+// Release gives back a reserved attempt whose password couldn't be verified, e.g. because the user store failed.
+func (t *LoginThrottle) Release(username, clientIP string) error {
+	return t.releaseKeys(t.keys(username, clientIP))
+}
+
+func (t *LoginThrottle) releaseKeys(keys []string) error {
+	for _, key := range keys {
+		err := t.store.RemoveFailure(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *LoginThrottle) maxFailures(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return t.maxFailuresPerIP
+	}
+	return t.maxFailuresPerUsername
+}
+
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if t.baseDelay <= 0 {
+		return 0
+	}
+
+	delay := t.baseDelay << min(failures-1, 30)
+	if delay > t.maxDelay || delay <= 0 {
+		return t.maxDelay
+	}
+	return delay
+}
+
+func (t *LoginThrottle) keys(username, clientIP string) []string {
+	keys := []string{usernameKey(username)}
+	if clientIP != "" {
+		keys = append(keys, ipKey(clientIP))
+	}
+	return keys
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// ObtainClientIP returns the IP address of the connection's peer. Deployments behind a reverse proxy must make
+// sure RemoteAddr reflects the real client, e.g. with a middleware that rewrites it from trusted headers.
+func ObtainClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,162 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingUserStore counts how many users were looked up.
+type countingUserStore struct {
+	model.UserStore
+	lookups atomic.Int32
+}
+
+func (s *countingUserStore) FindUserByUsername(username string) (*model.User, error) {
+	s.lookups.Add(1)
+	return s.UserStore.FindUserByUsername(username)
+}
+
+func TestCredentialVerifierThrottlesConcurrentGuesses(t *testing.T) {
+	const maxFailures = 5
+
+	inMemoryUserStore := Store.NewInMemoryUserStore()
+	inMemoryUserStore.AddUser("alice", encodePassword(t, "secret"))
+	userStore := &countingUserStore{UserStore: inMemoryUserStore}
+
+	throttle := NewLoginThrottle(Store.NewInMemoryLoginAttemptStore()).
+		WithThresholds(maxFailures, 1000, time.Minute).
+		WithDelays(0, 0)
+	verifier := NewCredentialVerifier(userStore).WithLoginThrottle(throttle)
+
+	var wg sync.WaitGroup
+	var throttled atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.Verify(httptest.NewRequest("POST", "/login", nil), "alice", "guess")
+			var throttledErr *LoginThrottledError
+			if errors.As(err, &throttledErr) {
+				throttled.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := userStore.lookups.Load(); got > maxFailures {
+		t.Fatalf("verified %d guesses, want at most %d", got, maxFailures)
+	}
+	if got := throttled.Load(); got < 50-maxFailures {
+		t.Fatalf("throttled %d guesses, want at least %d", got, 50-maxFailures)
+	}
+
+	_, err := verifier.Verify(httptest.NewRequest("POST", "/login", nil), "alice", "secret")
+	var throttledErr *LoginThrottledError
+	if !errors.As(err, &throttledErr) || !throttledErr.Locked {
+		t.Fatalf("got %v, want the username to be locked", err)
+	}
+}
+
+func TestCredentialVerifierGivesBackAttemptOnSuccess(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice", encodePassword(t, "secret"))
+
+	attemptStore := Store.NewInMemoryLoginAttemptStore()
+	verifier := NewCredentialVerifier(userStore).WithLoginThrottle(NewLoginThrottle(attemptStore))
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	_, err := verifier.Verify(req, "alice", "secret")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	for _, key := range []string{usernameKey("alice"), ipKey(ObtainClientIP(req))} {
+		attempts, _ := attemptStore.Find(key)
+		if attempts.Failures != 0 {
+			t.Errorf("%s has %d failures after a successful login, want 0", key, attempts.Failures)
+		}
+	}
+}
+
+func TestUsernamePasswordAuthIgnoresRequestsWithoutCredentials(t *testing.T) {
+	attemptStore := Store.NewInMemoryLoginAttemptStore()
+	verifier := NewCredentialVerifier(Store.NewInMemoryUserStore()).WithLoginThrottle(NewLoginThrottle(attemptStore))
+	auth := NewPasswordAuth(Store.NewInMemoryUserStore()).WithCredentialVerifier(verifier)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	authenticated, err := auth.Authenticate(httptest.NewRecorder(), req)
+	if authenticated || err != nil {
+		t.Fatalf("Authenticate = %v, %v; want false, nil", authenticated, err)
+	}
+
+	attempts, _ := attemptStore.Find(ipKey(ObtainClientIP(req)))
+	if attempts.Failures != 0 {
+		t.Fatalf("client IP has %d failures, want 0", attempts.Failures)
+	}
+}
+
+func TestLoginThrottleRejectionGivesBackReservedAttempts(t *testing.T) {
+	attemptStore := Store.NewInMemoryLoginAttemptStore()
+	throttle := NewLoginThrottle(attemptStore)
+
+	// The attacker's IP is locked out, yet keeps trying the victim's username
+	err := attemptStore.Lock(ipKey("203.0.113.7"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		var throttledErr *LoginThrottledError
+		if err := throttle.Reserve("alice", "203.0.113.7"); !errors.As(err, &throttledErr) {
+			t.Fatalf("attempt %d: got %v, want the client IP throttled", i+1, err)
+		}
+	}
+
+	attempts, _ := attemptStore.Find(usernameKey("alice"))
+	if attempts.Failures != 0 {
+		t.Fatalf("the victim's username has %d failures, want 0", attempts.Failures)
+	}
+
+	// The victim logging in from elsewhere isn't slowed down
+	err = throttle.Reserve("alice", "198.51.100.1")
+	if err != nil {
+		t.Fatalf("the victim was throttled: %v", err)
+	}
+}
+
+// staleLoginAttemptStore finds no attempts at all, as if every other attempt was recorded right after the lookup.
+type staleLoginAttemptStore struct {
+	*Store.InMemoryLoginAttemptStore
+}
+
+func (staleLoginAttemptStore) Find(key string) (Store.LoginAttempts, error) {
+	return Store.LoginAttempts{}, nil
+}
+
+func TestLoginThrottleRejectionOfInFlightAttemptGivesItBack(t *testing.T) {
+	attemptStore := staleLoginAttemptStore{Store.NewInMemoryLoginAttemptStore()}
+	throttle := NewLoginThrottle(attemptStore).WithThresholds(2, 50, time.Hour)
+
+	// Two attempts in flight
+	for i := 0; i < 2; i++ {
+		_, err := attemptStore.RecordFailure(usernameKey("alice"), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var throttledErr *LoginThrottledError
+	if err := throttle.Reserve("alice", "198.51.100.1"); !errors.As(err, &throttledErr) {
+		t.Fatalf("got %v, want the attempt beyond the threshold throttled", err)
+	}
+
+	attempts, _ := attemptStore.InMemoryLoginAttemptStore.Find(usernameKey("alice"))
+	if attempts.Failures != 2 {
+		t.Fatalf("got %d failures, want only the 2 in flight", attempts.Failures)
+	}
+}
@@ -0,0 +1,67 @@
+package Auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Notification kinds sent by this package.
+const (
+	PasswordResetNotification     = "password_reset"
+	EmailVerificationNotification = "email_verification"
+)
+
+// Notification carries a secret token to a user, e.g. in an email the Notifier renders.
+type Notification struct {
+	Kind      string
+	Recipient string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// Notifier delivers notifications to users, typically by email.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// RecordingNotifier keeps every notification in memory instead of delivering it, for tests and local development.
+type RecordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func NewRecordingNotifier() *RecordingNotifier {
+	return &RecordingNotifier{
+		notifications: make([]Notification, 0),
+	}
+}
+
+func (n *RecordingNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Notifications returns every notification recorded so far.
+func (n *RecordingNotifier) Notifications() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]Notification(nil), n.notifications...)
+}
+
+// Last returns the most recent notification for recipient.
+func (n *RecordingNotifier) Last(recipient string) (Notification, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := len(n.notifications) - 1; i >= 0; i-- {
+		if n.notifications[i].Recipient == recipient {
+			return n.notifications[i], true
+		}
+	}
+	return Notification{}, false
+}
@@ -0,0 +1,98 @@
+package Auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"time"
+)
+
+var (
+	ErrInvalidOpaqueToken = errors.New("invalid opaque token")
+)
+
+// GenerateOpaqueToken issues a random reference access token backed by accessTokenStore, together with a refresh token.
+// Unlike GenerateJWT, the access token carries no readable claims and can be revoked instantly by deleting it.
+func GenerateOpaqueToken(subject string, expiry time.Duration, accessTokenStore Store.AccessTokenStore, refreshTokenStore Store.RefreshTokenStore, opts ...TokenOption) (string, string, error) {
+	options := newTokenOptions(opts)
+
+	// Enforce the session limit before issuing anything
+	return options.sessionLimiter.issue(subject, refreshTokenStore, func() (string, string, error) {
+		accessToken, err := generateOpaqueTokenOnly(subject, options.accessTokenExpiryOr(expiry), accessTokenStore, options)
+		if err != nil {
+			return "", "", err
+		}
+
+		refreshToken, err := generateRefreshTokenOnly(subject, refreshTokenStore, options)
+		if err != nil {
+			return "", "", err
+		}
+
+		return accessToken, refreshToken, nil
+	})
+}
+
+// RefreshOpaqueToken exchanges a refresh token issued by GenerateOpaqueToken for a new opaque access token and
+// refresh token, applying the same checks as RefreshJWT.
+func RefreshOpaqueToken(refreshTokenString string, expiry time.Duration, accessTokenStore Store.AccessTokenStore, refreshTokenStore Store.RefreshTokenStore, opts ...TokenOption) (string, string, error) {
+	options := newTokenOptions(opts)
+
+	subject, newRefreshToken, err := rotateRefreshToken(refreshTokenString, refreshTokenStore, options)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := generateOpaqueTokenOnly(subject, options.accessTokenExpiryOr(expiry), accessTokenStore, options)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, newRefreshToken, nil
+}
+
+func generateOpaqueTokenOnly(subject string, expiry time.Duration, accessTokenStore Store.AccessTokenStore, options *tokenOptions) (string, error) {
+	accessToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = accessTokenStore.Save(accessToken, Store.AccessTokenRecord{
+		Subject:   subject,
+		ClientID:  options.clientID,
+		Scope:     options.scope,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(expiry),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return accessToken, nil
+}
+
+// ValidateOpaqueToken looks up an opaque access token and returns its record if it is still valid.
+func ValidateOpaqueToken(accessToken string, accessTokenStore Store.AccessTokenStore) (*Store.AccessTokenRecord, error) {
+	record, err := accessTokenStore.Find(accessToken)
+	if err != nil || record == nil {
+		return nil, ErrInvalidOpaqueToken
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidOpaqueToken
+	}
+
+	return record, nil
+}
+
+// randomToken returns size random bytes encoded as unpadded base64url.
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
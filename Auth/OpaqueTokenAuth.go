@@ -0,0 +1,128 @@
+package Auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type cachedIntrospection struct {
+	response  *IntrospectionResponse
+	expiresAt time.Time
+}
+
+// OpaqueTokenAuth validates opaque bearer tokens through an Introspector.
+// Active responses are cached for at most cacheTTL, which bounds how long a revoked token keeps working.
+type OpaqueTokenAuth struct {
+	introspector Introspector
+	cacheTTL     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedIntrospection
+}
+
+func NewOpaqueTokenAuth(introspector Introspector, cacheTTL time.Duration) *OpaqueTokenAuth {
+	return &OpaqueTokenAuth{
+		introspector: introspector,
+		cacheTTL:     cacheTTL,
+		cache:        make(map[string]cachedIntrospection),
+	}
+}
+
+func (o *OpaqueTokenAuth) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
+	token := ObtainBearerToken(r)
+	if token == "" {
+		return false, errors.New("missing Authorization header")
+	}
+
+	// Never keep raw tokens around in memory longer than needed
+	sum := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	if introspection, ok := o.cached(cacheKey); ok {
+		setPrincipal(r, introspectionPrincipal(introspection))
+		return true, nil
+	}
+
+	introspection, err := o.introspector.Introspect(r.Context(), token)
+	if err != nil {
+		return false, err
+	}
+
+	if !introspection.Active {
+		return false, ErrInvalidOpaqueToken
+	}
+
+	if introspection.ExpiresAt != 0 && time.Now().Unix() >= introspection.ExpiresAt {
+		return false, ErrInvalidOpaqueToken
+	}
+
+	o.store(cacheKey, introspection)
+
+	setPrincipal(r, introspectionPrincipal(introspection))
+
+	return true, nil
+}
+
+func introspectionPrincipal(introspection *IntrospectionResponse) *Principal {
+	principal := &Principal{
+		Subject:            introspection.Subject,
+		PasswordChangeOnly: hasScope(introspection.Scope, PasswordChangeScope),
+	}
+	if introspection.IssuedAt != 0 {
+		principal.IssuedAt = time.Unix(introspection.IssuedAt, 0)
+	}
+	return principal
+}
+
+func (o *OpaqueTokenAuth) cached(cacheKey string) (*IntrospectionResponse, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.cache[cacheKey]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(o.cache, cacheKey)
+		return nil, false
+	}
+
+	return entry.response, true
+}
+
+func (o *OpaqueTokenAuth) store(cacheKey string, introspection *IntrospectionResponse) {
+	if o.cacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(o.cacheTTL)
+
+	// Never cache beyond the token's own expiry
+	if introspection.ExpiresAt != 0 {
+		tokenExpiry := time.Unix(introspection.ExpiresAt, 0)
+		if tokenExpiry.Before(expiresAt) {
+			expiresAt = tokenExpiry
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Drop expired entries so the cache doesn't grow without bound
+	for key, entry := range o.cache {
+		if now.After(entry.expiresAt) {
+			delete(o.cache, key)
+		}
+	}
+
+	o.cache[cacheKey] = cachedIntrospection{
+		response:  introspection,
+		expiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,54 @@
+package Auth
+
+import (
+	"encoding/json"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRefreshHandlerWithOpaqueTokens(t *testing.T) {
+	accessTokenStore := Store.NewInMemoryAccessTokenStore()
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	handler := NewRefreshHandler(testSecret, time.Minute, refreshTokenStore).WithOpaqueTokens(accessTokenStore)
+
+	_, refreshToken, err := GenerateOpaqueToken("alice", time.Minute, accessTokenStore, refreshTokenStore, WithClientID("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh",
+			strings.NewReader(`{"refreshToken":"`+refreshToken+`"}`)))
+		return w
+	}
+
+	w := refresh(refreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: got status %d: %s", w.Code, w.Body)
+	}
+	var tokens TokenResponse
+	err = json.NewDecoder(w.Body).Decode(&tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := ValidateOpaqueToken(tokens.JWTToken, accessTokenStore)
+	if err != nil {
+		t.Fatalf("refreshed access token is not an opaque token: %v", err)
+	}
+	if record.Subject != "alice" || record.ClientID != "app" {
+		t.Fatalf("got %+v, want a token of alice for app", record)
+	}
+
+	if w := refresh(refreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := refresh(tokens.RefreshToken); w.Code != http.StatusOK {
+		t.Fatalf("new refresh token: got status %d: %s", w.Code, w.Body)
+	}
+}
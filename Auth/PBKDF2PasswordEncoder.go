@@ -0,0 +1,72 @@
+package Auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PBKDF2PasswordEncoder hashes passwords with PBKDF2-HMAC-SHA256, stored as $pbkdf2-sha256$i=<iterations>$salt$hash.
+// The default of 600,000 iterations follows the OWASP recommendation.
+type PBKDF2PasswordEncoder struct {
+	iterations int
+	saltLength int
+	keyLength  int
+}
+
+func NewPBKDF2PasswordEncoder() *PBKDF2PasswordEncoder {
+	return &PBKDF2PasswordEncoder{
+		iterations: 600_000,
+		saltLength: 16,
+		keyLength:  32,
+	}
+}
+
+// WithIterations sets the PBKDF2 iteration count.
+func (e *PBKDF2PasswordEncoder) WithIterations(iterations int) *PBKDF2PasswordEncoder {
+	e.iterations = iterations
+	return e
+}
+
+func (e *PBKDF2PasswordEncoder) Encode(rawPassword string) (string, error) {
+	salt, err := randomSalt(e.saltLength)
+	if err != nil {
+		return "", err
+	}
+
+	hash := pbkdf2.Key([]byte(rawPassword), salt, e.iterations, e.keyLength, sha256.New)
+
+	return formatPHC("pbkdf2-sha256", "", fmt.Sprintf("i=%d", e.iterations), salt, hash), nil
+}
+
+func (e *PBKDF2PasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	if phc.id != "pbkdf2-sha256" {
+		return false, fmt.Errorf("%w: not a pbkdf2-sha256 hash", ErrMalformedPasswordHash)
+	}
+
+	iterations, err := phc.intParam("i")
+	if err != nil {
+		return false, err
+	}
+
+	hash := pbkdf2.Key([]byte(rawPassword), phc.salt, iterations, len(phc.hash), sha256.New)
+
+	return subtle.ConstantTimeCompare(hash, phc.hash) == 1, nil
+}
+
+func (e *PBKDF2PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil || phc.id != "pbkdf2-sha256" {
+		return false
+	}
+
+	iterations, _ := phc.intParam("i")
+
+	return iterations < e.iterations || len(phc.salt) < e.saltLength || len(phc.hash) < e.keyLength
+}
@@ -0,0 +1,227 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPasswordChangeRequired = errors.New("password change required")
+	ErrPasswordReused         = errors.New("new password was used before")
+)
+
+// PasswordChangeScope is the only scope of tokens issued to users who must change their password.
+const PasswordChangeScope = "password_change"
+
+// passwordChangeTokenMaxExpiry caps the lifetime of restricted password-change tokens.
+const passwordChangeTokenMaxExpiry = 15 * time.Minute
+
+// GeneratePasswordChangeJWT issues a short-lived JWT restricted to PasswordChangeScope, without a refresh token.
+// AuthChain only accepts it on the path configured with SetPasswordChangePath.
+func GeneratePasswordChangeJWT(subject string, secret []byte, expiry time.Duration) (string, error) {
+	return generateJWTOnly(subject, secret, min(expiry, passwordChangeTokenMaxExpiry), newTokenOptions([]TokenOption{WithScope(PasswordChangeScope)}))
+}
+
+// hasScope reports whether a space-separated scope list contains want.
+func hasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// ************************************************************************ //
+
+// PasswordChanger lets authenticated users replace their password after re-entering the current one.
+// It also accepts users whose credentials expired or who must change their password, as long as they
+// authenticated through a session or token.
+type PasswordChanger struct {
+	userStore          model.UserStore
+	credentialVerifier *CredentialVerifier
+	passwordEncoder    PasswordEncoder
+	passwordPolicy     *PasswordPolicy
+	refreshTokenStore  Store.RefreshTokenStore
+	sessionStore       Store.SessionStore
+}
+
+func NewPasswordChanger(userStore model.UserStore) *PasswordChanger {
+	return &PasswordChanger{
+		userStore:          userStore,
+		credentialVerifier: NewCredentialVerifier(userStore),
+		passwordEncoder:    NewDefaultPasswordEncoder(),
+		passwordPolicy:     NewPasswordPolicy(),
+	}
+}
+
+func (c *PasswordChanger) WithPasswordEncoder(passwordEncoder PasswordEncoder) *PasswordChanger {
+	c.passwordEncoder = passwordEncoder
+	c.credentialVerifier.WithPasswordEncoder(passwordEncoder)
+	return c
+}
+
+func (c *PasswordChanger) WithPasswordPolicy(passwordPolicy *PasswordPolicy) *PasswordChanger {
+	c.passwordPolicy = passwordPolicy
+	return c
+}
+
+// WithLoginThrottle counts wrong current passwords as failed logins, so a stolen session can't be used to
+// guess the password.
+func (c *PasswordChanger) WithLoginThrottle(loginThrottle *LoginThrottle) *PasswordChanger {
+	c.credentialVerifier.WithLoginThrottle(loginThrottle)
+	return c
+}
+
+// WithSessionRevocation signs the user out everywhere else once the password changed: every refresh token in
+// refreshTokenStore and every session in sessionStore except the current one is deleted. Either store may be nil.
+// refreshTokenStore must implement Store.SubjectIndexedRefreshTokenStore and sessionStore
+// Store.SubjectIndexedSessionStore. Access tokens, including the one of the current request, are rejected by the
+// account status check of AuthChain from then on; without it they stay valid until they expire.
+func (c *PasswordChanger) WithSessionRevocation(refreshTokenStore Store.RefreshTokenStore, sessionStore Store.SessionStore) *PasswordChanger {
+	c.refreshTokenStore = refreshTokenStore
+	c.sessionStore = sessionStore
+	return c
+}
+
+// ChangePassword replaces the password of the authenticated principal of r. A *PasswordPolicyError is
+// returned if newPassword is rejected, and ErrInvalidCredentials if currentPassword is wrong.
+func (c *PasswordChanger) ChangePassword(r *http.Request, currentPassword, newPassword string) error {
+	principal := PrincipalFromRequest(r)
+	if principal == nil {
+		return ErrAuthenticationFailed
+	}
+
+	user, err := c.credentialVerifier.Verify(r, principal.Subject, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	if c.passwordPolicy != nil {
+		err = c.passwordPolicy.Validate(user.Username, newPassword)
+		if err != nil {
+			return err
+		}
+
+		err = c.passwordPolicy.CheckHistory(user, newPassword, c.passwordEncoder)
+		if err != nil {
+			return err
+		}
+	}
+
+	encodedPassword, err := c.passwordEncoder.Encode(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = c.userStore.ChangePassword(user.Username, encodedPassword)
+	if err != nil {
+		return err
+	}
+
+	return c.revokeOtherSessions(principal)
+}
+
+// revokeOtherSessions deletes every refresh token and every session but the current one of principal.
+func (c *PasswordChanger) revokeOtherSessions(principal *Principal) error {
+	if c.refreshTokenStore != nil {
+		err := RevokeAllRefreshTokens(principal.Subject, c.refreshTokenStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.sessionStore == nil {
+		return nil
+	}
+
+	indexedStore, ok := c.sessionStore.(Store.SubjectIndexedSessionStore)
+	if !ok {
+		return errors.New("session store cannot look up sessions by subject")
+	}
+
+	sessions, err := indexedStore.FindBySubject(principal.Subject)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if principal.Session != nil && session.ID == principal.Session.ID {
+			// The current session stays, without the password change restriction
+			delete(session.Attributes, passwordChangeOnlyAttribute)
+			err = c.sessionStore.Save(session)
+		} else {
+			err = c.sessionStore.Delete(session.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// Handler changes the password of the authenticated user. Mount it on the path set with
+// AuthChain.SetPasswordChangePath so users who must change their password can reach it.
+func (c *PasswordChanger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var changeReq ChangePasswordRequest
+		if !decodeJSONRequest(w, r, &changeReq) {
+			return
+		}
+
+		if PrincipalFromRequest(r) == nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+			return
+		}
+
+		err := c.ChangePassword(r, changeReq.CurrentPassword, changeReq.NewPassword)
+		if err != nil {
+			writePasswordChangeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// writePasswordChangeError maps the errors of setting a new password to a response.
+func writePasswordChangeError(w http.ResponseWriter, err error) {
+	var policyErr *PasswordPolicyError
+	var throttledErr *LoginThrottledError
+
+	switch {
+	case errors.Is(err, ErrInvalidResetToken):
+		writeError(w, http.StatusBadRequest, "invalid_token", "Invalid or expired password reset token")
+	case errors.Is(err, ErrInvalidCredentials):
+		writeError(w, http.StatusForbidden, "invalid_credentials", "Current password is incorrect")
+	case errors.As(err, &throttledErr):
+		throttledErr.setRetryAfter(w)
+		writeError(w, http.StatusTooManyRequests, "too_many_attempts", "Too many failed attempts, try again later")
+	case errors.Is(err, ErrPasswordReused):
+		writeError(w, http.StatusUnprocessableEntity, "password_reused", "New password was used before")
+	case errors.As(err, &policyErr):
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Error:      "password_policy_violation",
+			Message:    "New password does not meet the password policy",
+			Violations: policyErr.Violations,
+		})
+	default:
+		if code, ok := accountStatusErrorCode(err); ok {
+			writeError(w, http.StatusForbidden, code, err.Error())
+			return
+		}
+		log.Printf("Failed to change password: %v", err)
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to change password")
+	}
+}
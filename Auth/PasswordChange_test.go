@@ -0,0 +1,128 @@
+package Auth
+
+import (
+	"encoding/json"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"github.com/golang-jwt/jwt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordChangeRevokesEarlierAccessTokens(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.SaveUser(&model.User{
+		Username:           "alice",
+		Password:           encodePassword(t, "old password"),
+		PasswordChangedAt:  time.Now().Add(-time.Hour),
+		CredentialsExpired: true, // Changing expired credentials must still be possible
+	})
+
+	authChain := NewAuthChain(NewJWTAuth(testSecret), NewPasswordAuth(userStore))
+	router := http.NewServeMux()
+	router.Handle("POST /password/change", NewPasswordChanger(userStore).WithPasswordPolicy(nil).Handler())
+	router.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	handler := AuthMiddleware(authChain, router)
+
+	// A token issued a while ago, as tokens issued in the second of the change stay valid
+	issuedAt := time.Now().Add(-time.Minute)
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claim{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "alice",
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: issuedAt.Add(time.Hour).Unix(),
+		},
+	}).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := serve("GET", "/", ""); status != http.StatusOK {
+		t.Fatalf("before the change: got status %d, want %d", status, http.StatusOK)
+	}
+
+	status := serve("POST", "/password/change", `{"currentPassword":"old password","newPassword":"new password"}`)
+	if status != http.StatusNoContent {
+		t.Fatalf("change password: got status %d, want %d", status, http.StatusNoContent)
+	}
+
+	if status := serve("GET", "/", ""); status != http.StatusUnauthorized {
+		t.Fatalf("after the change: got status %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginWithExpiredPasswordIssuesPasswordChangeToken(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.SaveUser(&model.User{
+		Username:          "alice",
+		Password:          encodePassword(t, "old password"),
+		Roles:             []string{"admin"},
+		PasswordChangedAt: time.Now().Add(-48 * time.Hour),
+	})
+	passwordPolicy := NewPasswordPolicy().WithMaxAge(90*24*time.Hour).WithRoleMaxAge("admin", 24*time.Hour)
+
+	loginHandler := NewLoginHandler(userStore, testSecret, time.Minute, Store.NewInMemoryRefreshTokenStore()).
+		WithCredentialVerifier(NewCredentialVerifier(userStore).WithPasswordPolicy(passwordPolicy))
+	authChain := NewAuthChain(NewJWTAuth(testSecret)).WithAccountStatusCheck(userStore)
+	err := authChain.SetPasswordChangePath("^/password/change$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := http.NewServeMux()
+	router.Handle("POST /password/change", NewPasswordChanger(userStore).WithPasswordPolicy(passwordPolicy).Handler())
+	router.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	handler := AuthMiddleware(authChain, router)
+
+	login := func(password string) TokenResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		loginHandler.ServeHTTP(w, httptest.NewRequest("POST", "/login",
+			strings.NewReader(`{"username":"alice","password":"`+password+`"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("login: got status %d: %s", w.Code, w.Body)
+		}
+		var tokens TokenResponse
+		err := json.NewDecoder(w.Body).Decode(&tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+	serve := func(accessToken, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tokens := login("old password")
+	if !tokens.PasswordChangeRequired || tokens.RefreshToken != "" {
+		t.Fatalf("got %+v, want a password change token only", tokens)
+	}
+	if status := serve(tokens.JWTToken, "GET", "/", ""); status != http.StatusForbidden {
+		t.Fatalf("password change token elsewhere: got status %d, want %d", status, http.StatusForbidden)
+	}
+
+	status := serve(tokens.JWTToken, "POST", "/password/change",
+		`{"currentPassword":"old password","newPassword":"new password"}`)
+	if status != http.StatusNoContent {
+		t.Fatalf("change password: got status %d, want %d", status, http.StatusNoContent)
+	}
+
+	tokens = login("new password")
+	if tokens.PasswordChangeRequired || tokens.RefreshToken == "" {
+		t.Fatalf("got %+v, want a regular token pair after the change", tokens)
+	}
+}
@@ -0,0 +1,231 @@
+package Auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMalformedPasswordHash = errors.New("malformed password hash")
+)
+
+// PasswordEncoder hashes passwords for storage and checks raw passwords against stored hashes.
+type PasswordEncoder interface {
+	Encode(rawPassword string) (string, error)
+	Matches(rawPassword, encodedPassword string) (bool, error)
+
+	// UpgradeEncoding reports whether a stored hash is weaker than what Encode produces today
+	// and should be replaced on the next successful login.
+	UpgradeEncoding(encodedPassword string) bool
+}
+
+// NewDefaultPasswordEncoder returns a DelegatingPasswordEncoder that encodes with bcrypt and matches bcrypt,
+// argon2id, scrypt and PBKDF2 hashes. The ids Spring Security uses read Spring's hash formats, so the PHC
+// formatted scrypt and PBKDF2 hashes of this package have their own ids. Hashes without an {id} prefix are
+// recognized by their format, see FormatDetectingPasswordEncoder. Plain text {noop} and unsalted {SHA} hashes
+// are only matched after WithLegacyEncoders.
+func NewDefaultPasswordEncoder() *DelegatingPasswordEncoder {
+	argon2Encoder := NewArgon2PasswordEncoder() // Spring stores argon2 hashes in PHC format as well
+	springSCryptEncoder := NewSpringSCryptPasswordEncoder()
+
+	encoder := NewDelegatingPasswordEncoder("bcrypt", map[string]PasswordEncoder{
+		"bcrypt":                     NewBCryptPasswordEncoder(),
+		"argon2":                     argon2Encoder,
+		"argon2@SpringSecurity_v5_8": argon2Encoder,
+		"scrypt":                     springSCryptEncoder,
+		"scrypt@SpringSecurity_v5_8": springSCryptEncoder,
+		"pbkdf2":                     NewSpringPBKDF2PasswordEncoder(),
+		"pbkdf2@SpringSecurity_v5_8": NewSpringV58PBKDF2PasswordEncoder(),
+		"scrypt-phc":                 NewSCryptPasswordEncoder(),
+		"pbkdf2-phc":                 NewPBKDF2PasswordEncoder(),
+	})
+	encoder.WithDefaultPasswordEncoderForMatches(NewFormatDetectingPasswordEncoder())
+
+	return encoder
+}
+
+// DelegatingPasswordEncoder prefixes every hash with the {id} of the encoder that produced it,
+// so a single user table can hold hashes of several formats.
+type DelegatingPasswordEncoder struct {
+	idForEncode                      string
+	encoders                         map[string]PasswordEncoder
+	defaultPasswordEncoderForMatches PasswordEncoder
+}
+
+func NewDelegatingPasswordEncoder(idForEncode string, encoders map[string]PasswordEncoder) *DelegatingPasswordEncoder {
+	return &DelegatingPasswordEncoder{
+		idForEncode: idForEncode,
+		encoders:    encoders,
+	}
+}
+
+// WithLegacyEncoders also matches plain text {noop} and unsalted SHA-1 {SHA} hashes, as Spring Security and
+// htpasswd store them. Anyone who can write a user row can then set a password of their choosing, so enable
+// it only while migrating such hashes; UpgradeEncoding replaces them on the next successful login.
+func (d *DelegatingPasswordEncoder) WithLegacyEncoders() *DelegatingPasswordEncoder {
+	d.encoders["noop"] = NewNoOpPasswordEncoder()
+	d.encoders["SHA"] = NewSHA1PasswordEncoder()
+	return d
+}
+
+// WithDefaultPasswordEncoderForMatches sets the encoder used for hashes that carry no {id} prefix.
+func (d *DelegatingPasswordEncoder) WithDefaultPasswordEncoderForMatches(encoder PasswordEncoder) *DelegatingPasswordEncoder {
+	d.defaultPasswordEncoderForMatches = encoder
+	return d
+}
+
+func (d *DelegatingPasswordEncoder) Encode(rawPassword string) (string, error) {
+	encoder, ok := d.encoders[d.idForEncode]
+	if !ok {
+		return "", fmt.Errorf("no password encoder registered for id %q", d.idForEncode)
+	}
+
+	encoded, err := encoder.Encode(rawPassword)
+	if err != nil {
+		return "", err
+	}
+
+	return "{" + d.idForEncode + "}" + encoded, nil
+}
+
+func (d *DelegatingPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	encoder, encoded, err := d.delegateFor(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	return encoder.Matches(rawPassword, encoded)
+}
+
+// UpgradeEncoding reports true for hashes of another encoder than the one used for encoding,
+// and otherwise defers to that encoder.
+func (d *DelegatingPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	id, encoded, ok := extractEncoderID(encodedPassword)
+	if !ok || id != d.idForEncode {
+		return true
+	}
+
+	encoder, exists := d.encoders[id]
+	if !exists {
+		return true
+	}
+
+	return encoder.UpgradeEncoding(encoded)
+}
+
+// delegateFor picks the encoder for a stored hash and strips its {id} prefix.
+func (d *DelegatingPasswordEncoder) delegateFor(encodedPassword string) (PasswordEncoder, string, error) {
+	id, encoded, ok := extractEncoderID(encodedPassword)
+	if !ok {
+		if d.defaultPasswordEncoderForMatches == nil {
+			return nil, "", fmt.Errorf("%w: missing {id} prefix", ErrMalformedPasswordHash)
+		}
+		return d.defaultPasswordEncoderForMatches, encodedPassword, nil
+	}
+
+	encoder, exists := d.encoders[id]
+	if !exists {
+		return nil, "", fmt.Errorf("no password encoder registered for id %q", id)
+	}
+
+	return encoder, encoded, nil
+}
+
+func extractEncoderID(encodedPassword string) (string, string, bool) {
+	if !strings.HasPrefix(encodedPassword, "{") {
+		return "", "", false
+	}
+
+	end := strings.Index(encodedPassword, "}")
+	if end < 0 {
+		return "", "", false
+	}
+
+	return encodedPassword[1:end], encodedPassword[end+1:], true
+}
+
+// ************************************************************************ //
+
+// phcHash is a hash in PHC string format: $id[$v=version][$param=value,...]$salt$hash
+type phcHash struct {
+	id      string
+	version string
+	params  map[string]string
+	salt    []byte
+	hash    []byte
+}
+
+func parsePHC(encoded string) (*phcHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || len(parts) > 6 || parts[0] != "" {
+		return nil, ErrMalformedPasswordHash
+	}
+
+	phc := &phcHash{
+		id:     parts[1],
+		params: make(map[string]string),
+	}
+
+	rest := parts[2:]
+	if len(rest) == 4 {
+		version, found := strings.CutPrefix(rest[0], "v=")
+		if !found {
+			return nil, ErrMalformedPasswordHash
+		}
+		phc.version = version
+		rest = rest[1:]
+	}
+
+	for _, param := range strings.Split(rest[0], ",") {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			return nil, ErrMalformedPasswordHash
+		}
+		phc.params[key] = value
+	}
+
+	var err error
+	phc.salt, err = base64.RawStdEncoding.DecodeString(rest[1])
+	if err != nil {
+		return nil, ErrMalformedPasswordHash
+	}
+	phc.hash, err = base64.RawStdEncoding.DecodeString(rest[2])
+	if err != nil || len(phc.hash) == 0 {
+		return nil, ErrMalformedPasswordHash
+	}
+
+	return phc, nil
+}
+
+func (p *phcHash) intParam(name string) (int, error) {
+	value, err := strconv.Atoi(p.params[name])
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%w: invalid parameter %s", ErrMalformedPasswordHash, name)
+	}
+	return value, nil
+}
+
+func formatPHC(id, version, params string, salt, hash []byte) string {
+	var sb strings.Builder
+	sb.WriteString("$" + id)
+	if version != "" {
+		sb.WriteString("$v=" + version)
+	}
+	sb.WriteString("$" + params)
+	sb.WriteString("$" + base64.RawStdEncoding.EncodeToString(salt))
+	sb.WriteString("$" + base64.RawStdEncoding.EncodeToString(hash))
+	return sb.String()
+}
+
+func randomSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
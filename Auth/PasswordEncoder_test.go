@@ -0,0 +1,201 @@
+package Auth
+
+import (
+	"errors"
+	"testing"
+)
+
+// encodePassword hashes rawPassword the way a user row stores it by default.
+func encodePassword(t *testing.T, rawPassword string) string {
+	t.Helper()
+
+	encoded, err := NewDefaultPasswordEncoder().Encode(rawPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestPasswordEncodersRoundTrip(t *testing.T) {
+	encoders := map[string]PasswordEncoder{
+		"bcrypt":     NewBCryptPasswordEncoder().WithCost(4),
+		"argon2":     NewArgon2PasswordEncoder().WithParameters(1024, 1, 1),
+		"scrypt-phc": NewSCryptPasswordEncoder().WithParameters(10, 8, 1),
+		"pbkdf2-phc": NewPBKDF2PasswordEncoder().WithIterations(1000),
+	}
+
+	for id, encoder := range encoders {
+		t.Run(id, func(t *testing.T) {
+			hash, err := encoder.Encode("pässwörd")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if matches, err := encoder.Matches("pässwörd", hash); err != nil || !matches {
+				t.Fatalf("Matches(right password) = %v, %v; want true", matches, err)
+			}
+			if matches, err := encoder.Matches("passwörd", hash); err != nil || matches {
+				t.Fatalf("Matches(wrong password) = %v, %v; want false", matches, err)
+			}
+			if encoder.UpgradeEncoding(hash) {
+				t.Fatal("a hash just produced by the encoder needs upgrading")
+			}
+
+			other, err := encoder.Encode("pässwörd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == hash {
+				t.Fatal("two hashes of the same password are equal, the salt is not random")
+			}
+		})
+	}
+}
+
+func TestPasswordEncodersUpgradeWeakerParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		weaker   PasswordEncoder
+		stronger PasswordEncoder
+	}{
+		{"bcrypt", NewBCryptPasswordEncoder().WithCost(4), NewBCryptPasswordEncoder().WithCost(5)},
+		{"argon2", NewArgon2PasswordEncoder().WithParameters(1024, 1, 1), NewArgon2PasswordEncoder().WithParameters(1024, 2, 1)},
+		{"scrypt-phc", NewSCryptPasswordEncoder().WithParameters(10, 8, 1), NewSCryptPasswordEncoder().WithParameters(11, 8, 1)},
+		{"pbkdf2-phc", NewPBKDF2PasswordEncoder().WithIterations(1000), NewPBKDF2PasswordEncoder().WithIterations(2000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.weaker.Encode("password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.stronger.UpgradeEncoding(hash) {
+				t.Fatal("a hash with weaker parameters does not need upgrading")
+			}
+			if matches, err := tt.stronger.Matches("password", hash); err != nil || !matches {
+				t.Fatalf("Matches = %v, %v; want the weaker hash to still match", matches, err)
+			}
+		})
+	}
+}
+
+func TestDefaultPasswordEncoderUpgradeEncoding(t *testing.T) {
+	encoder := NewDefaultPasswordEncoder()
+
+	if encoder.UpgradeEncoding(encodePassword(t, "password")) {
+		t.Fatal("a hash of the current default encoder needs upgrading")
+	}
+
+	hashes := []string{
+		"{argon2}$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"{pbkdf2}5d923b44a6d129f3ddf3e3c8d29412723dcbde72445e8ef6bf3b508fbf17fa4ed4d6b99ca763d8dc",
+		"$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG", // Matched by format, but without an {id}
+		"{noop}password",
+	}
+	for _, hash := range hashes {
+		if !encoder.UpgradeEncoding(hash) {
+			t.Errorf("UpgradeEncoding(%q) = false, want true", hash)
+		}
+	}
+}
+
+// The hashes below are the examples of Spring Security's DelegatingPasswordEncoder documentation, all of "password".
+func TestDefaultPasswordEncoderMatchesSpringHashes(t *testing.T) {
+	hashes := []string{
+		"{bcrypt}$2a$10$dXJ3SW6G7P50lGmMkkmwe.20cQQubK3.HZWzG3YB1tlRy.fqvM/BG",
+		"{pbkdf2}5d923b44a6d129f3ddf3e3c8d29412723dcbde72445e8ef6bf3b508fbf17fa4ed4d6b99ca763d8dc",
+		"{scrypt}$e0801$8bWJaSu2IKSn9Z9kM+TPXfOc/9bdYSrN1oD9qfVThWEwdRTnO7re7Ei+fUZRJ68k9lTyuTeUp4of4g24hHnazw==$OAOec05+bXxvuu/1qZ6NUR+xQYvYv7BeL1QxwRpY5Pc=",
+	}
+
+	encoder := NewDefaultPasswordEncoder()
+	for _, hash := range hashes {
+		matches, err := encoder.Matches("password", hash)
+		if err != nil || !matches {
+			t.Errorf("Matches(%q) = %v, %v; want true", hash, matches, err)
+		}
+		if matches, err := encoder.Matches("wrong password", hash); err != nil || matches {
+			t.Errorf("Matches(wrong password, %q) = %v, %v; want false", hash, matches, err)
+		}
+	}
+}
+
+func TestDefaultPasswordEncoderMatchesPHCHashes(t *testing.T) {
+	encoders := map[string]PasswordEncoder{
+		"pbkdf2-phc": NewPBKDF2PasswordEncoder().WithIterations(1000),
+		"scrypt-phc": NewSCryptPasswordEncoder().WithParameters(10, 8, 1),
+		"argon2":     NewArgon2PasswordEncoder().WithParameters(1024, 1, 1),
+	}
+
+	encoder := NewDefaultPasswordEncoder()
+	for id, phcEncoder := range encoders {
+		hash, err := phcEncoder.Encode("password")
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", id, err)
+		}
+
+		matches, err := encoder.Matches("password", "{"+id+"}"+hash)
+		if err != nil || !matches {
+			t.Errorf("Matches(%q) = %v, %v; want true", "{"+id+"}"+hash, matches, err)
+		}
+	}
+}
+
+// The hashes below were produced by openssl passwd -apr1 and Python's hashlib, all of "password".
+func TestDefaultPasswordEncoderMatchesHashesWithoutID(t *testing.T) {
+	hashes := []string{
+		"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/",
+		"pbkdf2_sha256$1000$salt$YywoEuRtRgQQK6dhjp1tfS+BKPYma0oDJk0qBGC33LM=",
+		"pbkdf2_sha1$1000$salt$boi+i61+rp2eEKoGEiQDT+1I0D8=",
+	}
+
+	encoder := NewDefaultPasswordEncoder()
+	for _, hash := range hashes {
+		if matches, err := encoder.Matches("password", hash); err != nil || !matches {
+			t.Errorf("Matches(%q) = %v, %v; want true", hash, matches, err)
+		}
+		if matches, err := encoder.Matches("wrong password", hash); err != nil || matches {
+			t.Errorf("Matches(wrong password, %q) = %v, %v; want false", hash, matches, err)
+		}
+		if !encoder.UpgradeEncoding(hash) {
+			t.Errorf("UpgradeEncoding(%q) = false, want true", hash)
+		}
+	}
+
+	_, err := encoder.Matches("password", "$1$saltsalt$qjXMvbEw8oaL.CzflDugX/")
+	if !errors.Is(err, ErrMalformedPasswordHash) {
+		t.Fatalf("an unrecognized format: got %v, want %v", err, ErrMalformedPasswordHash)
+	}
+}
+
+func TestDefaultPasswordEncoderLegacyEncodersAreOptIn(t *testing.T) {
+	// {SHA} is the unsalted, base64 encoded SHA-1 of "password"
+	hashes := []string{"{noop}password", "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g="}
+
+	for _, hash := range hashes {
+		matches, err := NewDefaultPasswordEncoder().Matches("password", hash)
+		if err == nil || matches {
+			t.Errorf("by default: Matches(%q) = %v, %v; want an error", hash, matches, err)
+		}
+
+		encoder := NewDefaultPasswordEncoder().WithLegacyEncoders()
+		if matches, err := encoder.Matches("password", hash); err != nil || !matches {
+			t.Errorf("with legacy encoders: Matches(%q) = %v, %v; want true", hash, matches, err)
+		}
+		if matches, err := encoder.Matches("wrong password", hash); err != nil || matches {
+			t.Errorf("with legacy encoders: Matches(wrong password, %q) = %v, %v; want false", hash, matches, err)
+		}
+		if !encoder.UpgradeEncoding(hash) {
+			t.Errorf("UpgradeEncoding(%q) = false, want true", hash)
+		}
+	}
+
+	// Enabling them does not change what new hashes look like
+	hash, err := NewDefaultPasswordEncoder().WithLegacyEncoders().Encode("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _ := extractEncoderID(hash); id != "bcrypt" {
+		t.Fatalf("got a {%s} hash, want {bcrypt}", id)
+	}
+}
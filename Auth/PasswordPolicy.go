@@ -0,0 +1,283 @@
+package Auth
+
+import (
+	"fmt"
+	"github.com/ayushs-2k4/go-security/model"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation codes reported by PasswordPolicy.
+const (
+	ViolationTooShort          = "too_short"
+	ViolationTooLong           = "too_long"
+	ViolationMissingUppercase  = "missing_uppercase"
+	ViolationMissingLowercase  = "missing_lowercase"
+	ViolationMissingDigit      = "missing_digit"
+	ViolationMissingSymbol     = "missing_symbol"
+	ViolationSimilarToUsername = "similar_to_username"
+	ViolationBreached          = "breached"
+)
+
+// PolicyViolation is one rule a password breaks.
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned by PasswordPolicy.Validate and lists every violated rule.
+type PasswordPolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "password does not meet policy: " + strings.Join(messages, "; ")
+}
+
+// PasswordPolicy validates password quality for signup and change-password flows.
+// The defaults follow NIST SP 800-63B: 8 to 64 characters, no composition rules, no passwords resembling
+// the username. The length in bytes is additionally capped at 72, as bcrypt ignores everything beyond.
+type PasswordPolicy struct {
+	minLength               int
+	maxLength               int
+	maxBytes                int
+	requireUppercase        bool
+	requireLowercase        bool
+	requireDigit            bool
+	requireSymbol           bool
+	checkUsername           bool
+	breachedPasswordChecker BreachedPasswordChecker
+	historySize             int
+	maxAge                  time.Duration
+	roleMaxAges             map[string]time.Duration
+}
+
+func NewPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		minLength:     8,
+		maxLength:     64,
+		maxBytes:      72,
+		checkUsername: true,
+		historySize:   1,
+		roleMaxAges:   make(map[string]time.Duration),
+	}
+}
+
+// WithLength sets the minimum and maximum number of characters (runes).
+func (p *PasswordPolicy) WithLength(minLength, maxLength int) *PasswordPolicy {
+	p.minLength = minLength
+	p.maxLength = maxLength
+	return p
+}
+
+// WithMaxBytes sets the maximum UTF-8 encoded length, 72 by default for bcrypt; 0 disables the check.
+func (p *PasswordPolicy) WithMaxBytes(maxBytes int) *PasswordPolicy {
+	p.maxBytes = maxBytes
+	return p
+}
+
+// WithRequiredCharacterClasses requires at least one character of each enabled class.
+func (p *PasswordPolicy) WithRequiredCharacterClasses(uppercase, lowercase, digit, symbol bool) *PasswordPolicy {
+	p.requireUppercase = uppercase
+	p.requireLowercase = lowercase
+	p.requireDigit = digit
+	p.requireSymbol = symbol
+	return p
+}
+
+// WithUsernameCheck enables or disables rejecting passwords similar to the username.
+func (p *PasswordPolicy) WithUsernameCheck(enabled bool) *PasswordPolicy {
+	p.checkUsername = enabled
+	return p
+}
+
+// WithBreachedPasswordChecker rejects passwords known from breaches.
+func (p *PasswordPolicy) WithBreachedPasswordChecker(checker BreachedPasswordChecker) *PasswordPolicy {
+	p.breachedPasswordChecker = checker
+	return p
+}
+
+// WithHistory rejects new passwords equal to any of the last n passwords, the current one included.
+// The default of 1 only rejects the current password; 0 disables the check.
+func (p *PasswordPolicy) WithHistory(n int) *PasswordPolicy {
+	p.historySize = n
+	return p
+}
+
+// WithMaxAge expires passwords of all users after maxAge; 0, the default, lets them live forever.
+func (p *PasswordPolicy) WithMaxAge(maxAge time.Duration) *PasswordPolicy {
+	p.maxAge = maxAge
+	return p
+}
+
+// WithRoleMaxAge expires passwords of users with role after maxAge, e.g. for privileged accounts.
+// The shortest maximum age that applies to a user wins.
+func (p *PasswordPolicy) WithRoleMaxAge(role string, maxAge time.Duration) *PasswordPolicy {
+	p.roleMaxAges[role] = maxAge
+	return p
+}
+
+// CheckHistory returns ErrPasswordReused if password matches one of the last passwords of user, as
+// configured with WithHistory.
+func (p *PasswordPolicy) CheckHistory(user *model.User, password string, passwordEncoder PasswordEncoder) error {
+	if p.historySize <= 0 {
+		return nil
+	}
+
+	previousPasswords := append([]string{user.Password}, user.PasswordHistory...)
+	for _, encodedPassword := range previousPasswords[:min(p.historySize, len(previousPasswords))] {
+		matches, err := passwordEncoder.Matches(password, encodedPassword)
+		if err == nil && matches {
+			return ErrPasswordReused
+		}
+	}
+
+	return nil
+}
+
+// IsExpired reports whether the password of user is older than the maximum age that applies to them.
+// Passwords without a known change time never expire.
+func (p *PasswordPolicy) IsExpired(user *model.User) bool {
+	maxAge := p.maxAge
+	for role, roleMaxAge := range p.roleMaxAges {
+		if user.HasRole(role) && roleMaxAge > 0 && (maxAge == 0 || roleMaxAge < maxAge) {
+			maxAge = roleMaxAge
+		}
+	}
+
+	if maxAge == 0 || user.PasswordChangedAt.IsZero() {
+		return false
+	}
+
+	return time.Since(user.PasswordChangedAt) > maxAge
+}
+
+// Validate returns a *PasswordPolicyError listing every violation, nil if the password is acceptable,
+// or another error if the breached password lookup itself failed.
+func (p *PasswordPolicy) Validate(username, password string) error {
+	violations := make([]PolicyViolation, 0)
+	violate := func(code, format string, args ...any) {
+		violations = append(violations, PolicyViolation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		violate(ViolationTooShort, "must be at least %d characters long", p.minLength)
+	}
+	if p.maxLength > 0 && length > p.maxLength {
+		violate(ViolationTooLong, "must be at most %d characters long", p.maxLength)
+	} else if p.maxBytes > 0 && len(password) > p.maxBytes {
+		violate(ViolationTooLong, "must be at most %d bytes long", p.maxBytes)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case !unicode.IsLetter(c) && !unicode.IsSpace(c):
+			hasSymbol = true
+		}
+	}
+
+	if p.requireUppercase && !hasUpper {
+		violate(ViolationMissingUppercase, "must contain an uppercase letter")
+	}
+	if p.requireLowercase && !hasLower {
+		violate(ViolationMissingLowercase, "must contain a lowercase letter")
+	}
+	if p.requireDigit && !hasDigit {
+		violate(ViolationMissingDigit, "must contain a digit")
+	}
+	if p.requireSymbol && !hasSymbol {
+		violate(ViolationMissingSymbol, "must contain a symbol")
+	}
+
+	if p.checkUsername && isSimilarToUsername(username, password) {
+		violate(ViolationSimilarToUsername, "must not resemble the username")
+	}
+
+	if p.breachedPasswordChecker != nil {
+		breached, err := p.breachedPasswordChecker.IsBreached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violate(ViolationBreached, "appears in a list of breached passwords")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// isSimilarToUsername catches passwords containing the username (or the local part of an email address),
+// its reverse, or differing from it by only a couple of edits.
+func isSimilarToUsername(username, password string) bool {
+	password = strings.ToLower(password)
+
+	candidates := []string{strings.ToLower(username)}
+	if localPart, _, found := strings.Cut(candidates[0], "@"); found {
+		candidates = append(candidates, localPart)
+	}
+
+	for _, candidate := range candidates {
+		if utf8.RuneCountInString(candidate) < 3 {
+			continue
+		}
+
+		if strings.Contains(password, candidate) || strings.Contains(password, reverse(candidate)) {
+			return true
+		}
+		if levenshtein(password, candidate) <= 2 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,151 @@
+package Auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func violationCodes(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("got error %v, want a *PasswordPolicyError", err)
+	}
+
+	codes := make([]string, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"too short", NewPasswordPolicy(), "seven77", []string{ViolationTooShort}},
+		{"minimum length", NewPasswordPolicy(), "eight888", nil},
+		{"maximum length", NewPasswordPolicy(), strings.Repeat("x", 64), nil},
+		{"too long", NewPasswordPolicy(), strings.Repeat("x", 65), []string{ViolationTooLong}},
+		{"short in bytes, not in characters", NewPasswordPolicy(), "ééé", []string{ViolationTooShort}},
+		// 40 characters, but 80 bytes of which bcrypt would ignore the last 8
+		{"beyond the bcrypt limit", NewPasswordPolicy(), strings.Repeat("é", 40), []string{ViolationTooLong}},
+		{"at the bcrypt limit", NewPasswordPolicy().WithLength(8, 100), strings.Repeat("x", 72), nil},
+		{"one byte beyond the bcrypt limit", NewPasswordPolicy().WithLength(8, 100), strings.Repeat("x", 73), []string{ViolationTooLong}},
+		{"byte limit disabled", NewPasswordPolicy().WithLength(8, 100).WithMaxBytes(0), strings.Repeat("x", 100), nil},
+		{"character limit disabled", NewPasswordPolicy().WithLength(8, 0).WithMaxBytes(0), strings.Repeat("x", 1000), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violationCodes(t, tt.policy.Validate("alice", tt.password))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got violations %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCharacterClasses(t *testing.T) {
+	policy := NewPasswordPolicy().WithRequiredCharacterClasses(true, true, true, true)
+
+	got := violationCodes(t, policy.Validate("alice", "all lowercase"))
+	want := []string{ViolationMissingUppercase, ViolationMissingDigit, ViolationMissingSymbol}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got violations %v, want %v", got, want)
+	}
+
+	if err := policy.Validate("alice", "Üppercase 4 all!"); err != nil {
+		t.Fatalf("a password with every class: %v", err)
+	}
+
+	// Composition rules are off by default
+	if err := NewPasswordPolicy().Validate("alice", "all lowercase"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordPolicySimilarToUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		password string
+		similar  bool
+	}{
+		{"alice", "alice2024!", true},
+		{"alice", "my ALICE password", true},
+		{"alice", "ecila is me", true},
+		{"johnsmith", "jonsmith1", true}, // Two edits away
+		{"carol@example.com", "carol rocks", true},
+		{"carol@example.com", "example.com rules", false},
+		{"alice", "correct horse", false},
+		{"bo", "bobobobo", false}, // Usernames too short to compare
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.username+"/"+tt.password, func(t *testing.T) {
+			got := violationCodes(t, NewPasswordPolicy().Validate(tt.username, tt.password))
+			if similar := slices.Contains(got, ViolationSimilarToUsername); similar != tt.similar {
+				t.Fatalf("got violations %v, want similar to username: %v", got, tt.similar)
+			}
+		})
+	}
+
+	if err := NewPasswordPolicy().WithUsernameCheck(false).Validate("alice", "alice2024!"); err != nil {
+		t.Fatalf("username check disabled: %v", err)
+	}
+}
+
+type failingBreachedPasswordChecker struct{}
+
+func (failingBreachedPasswordChecker) IsBreached(password string) (bool, error) {
+	return false, errors.New("corpus unavailable")
+}
+
+func TestPasswordPolicyBreachedPasswords(t *testing.T) {
+	list := writeBreachedPasswordList(t, "password\n123456\n")
+	policy := NewPasswordPolicy().WithBreachedPasswordChecker(list)
+
+	err := policy.Validate("alice", "password")
+	if got := violationCodes(t, err); !slices.Equal(got, []string{ViolationBreached}) {
+		t.Fatalf("got violations %v, want %v", got, []string{ViolationBreached})
+	}
+	if !strings.Contains(err.Error(), "breached") {
+		t.Fatalf("got message %q", err.Error())
+	}
+
+	if err := policy.Validate("alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed lookup is reported as such rather than as a violation
+	err = NewPasswordPolicy().WithBreachedPasswordChecker(failingBreachedPasswordChecker{}).Validate("alice", "correct horse")
+	var policyErr *PasswordPolicyError
+	if err == nil || errors.As(err, &policyErr) {
+		t.Fatalf("got error %v, want the lookup failure", err)
+	}
+}
+
+func TestPasswordPolicyReportsEveryViolation(t *testing.T) {
+	err := NewPasswordPolicy().WithRequiredCharacterClasses(false, false, true, false).Validate("alice", "alice")
+
+	got := violationCodes(t, err)
+	want := []string{ViolationTooShort, ViolationMissingDigit, ViolationSimilarToUsername}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got violations %v, want %v", got, want)
+	}
+
+	message := err.Error()
+	if !strings.Contains(message, "at least 8 characters") || !strings.Contains(message, "digit") || !strings.Contains(message, "username") {
+		t.Fatalf("got message %q, want every violation in it", message)
+	}
+}
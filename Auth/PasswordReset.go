@@ -0,0 +1,286 @@
+package Auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// queuedResetRequest is a reset requested through RequestHandler, waiting for a worker to send it.
+type queuedResetRequest struct {
+	ctx      context.Context
+	username string
+}
+
+// PasswordReset lets users who forgot their password set a new one through a time-limited, single-use token
+// delivered by a Notifier. Only a SHA-256 hash of the token is stored.
+type PasswordReset struct {
+	userStore         model.UserStore
+	tokenStore        Store.PasswordResetTokenStore
+	notifier          Notifier
+	refreshTokenStore Store.RefreshTokenStore
+	passwordEncoder   PasswordEncoder
+	passwordPolicy    *PasswordPolicy
+	expiry            time.Duration
+	requestThrottle   *LoginThrottle
+
+	workers      int
+	queue        chan queuedResetRequest
+	startWorkers sync.Once
+}
+
+// NewPasswordReset creates a PasswordReset. On completion every refresh token of the user in refreshTokenStore
+// is revoked, which requires it to implement Store.SubjectIndexedRefreshTokenStore; it may be nil.
+func NewPasswordReset(userStore model.UserStore, tokenStore Store.PasswordResetTokenStore, notifier Notifier, refreshTokenStore Store.RefreshTokenStore) *PasswordReset {
+	return &PasswordReset{
+		userStore:         userStore,
+		tokenStore:        tokenStore,
+		notifier:          notifier,
+		refreshTokenStore: refreshTokenStore,
+		passwordEncoder:   NewDefaultPasswordEncoder(),
+		passwordPolicy:    NewPasswordPolicy(),
+		expiry:            30 * time.Minute,
+		requestThrottle: NewLoginThrottle(Store.NewInMemoryLoginAttemptStore()).
+			WithThresholds(5, 50, time.Hour).
+			WithDelays(0, 0).
+			WithFailureWindow(time.Hour),
+		workers: 4,
+		queue:   make(chan queuedResetRequest, 100),
+	}
+}
+
+// WithExpiry sets how long a reset token stays valid.
+func (p *PasswordReset) WithExpiry(expiry time.Duration) *PasswordReset {
+	p.expiry = expiry
+	return p
+}
+
+func (p *PasswordReset) WithPasswordEncoder(passwordEncoder PasswordEncoder) *PasswordReset {
+	p.passwordEncoder = passwordEncoder
+	return p
+}
+
+func (p *PasswordReset) WithPasswordPolicy(passwordPolicy *PasswordPolicy) *PasswordReset {
+	p.passwordPolicy = passwordPolicy
+	return p
+}
+
+// WithRequestThrottle limits how often RequestHandler accepts requests per username and per client IP, counting
+// every request as a failure of throttle, so nobody can flood a mailbox. Give it a LoginAttemptStore of its own,
+// not the one used for logins. The default allows 5 requests per username and 50 per client IP an hour; nil
+// disables the limit.
+func (p *PasswordReset) WithRequestThrottle(throttle *LoginThrottle) *PasswordReset {
+	p.requestThrottle = throttle
+	return p
+}
+
+// WithWorkers sets how many resets RequestHandler sends at once, 4 by default, and how many more may wait,
+// 100 by default. Requests beyond that are answered with 503. It must be called before RequestHandler is used.
+func (p *PasswordReset) WithWorkers(workers, queueSize int) *PasswordReset {
+	p.workers = workers
+	p.queue = make(chan queuedResetRequest, queueSize)
+	return p
+}
+
+// RequestReset sends a reset token to username. Unknown usernames are silently ignored, but they return
+// faster than known ones; callers that answer clients should run it in the background like RequestHandler.
+func (p *PasswordReset) RequestReset(ctx context.Context, username string) error {
+	user, err := p.userStore.FindUserByUsername(username)
+	if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(p.expiry)
+	err = p.tokenStore.Save(hashResetToken(token), Store.PasswordResetToken{
+		Subject:   user.Username,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.notifier.Notify(ctx, Notification{
+		Kind:      PasswordResetNotification,
+		Recipient: user.Username,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// CompleteReset sets newPassword for the owner of token and revokes all of their refresh tokens and pending
+// reset tokens. A *PasswordPolicyError is returned if newPassword is rejected; the token then stays valid so
+// the user can try another password.
+func (p *PasswordReset) CompleteReset(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	tokenHash := hashResetToken(token)
+	resetToken, err := p.tokenStore.Find(tokenHash)
+	if err != nil || resetToken == nil || time.Now().After(resetToken.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	if p.passwordPolicy != nil {
+		err = p.passwordPolicy.Validate(resetToken.Subject, newPassword)
+		if err != nil {
+			return err
+		}
+
+		user, err := p.userStore.FindUserByUsername(resetToken.Subject)
+		if errors.Is(err, model.ErrUserNotFound) || (err == nil && user == nil) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		err = p.passwordPolicy.CheckHistory(user, newPassword, p.passwordEncoder)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only now consume the token, atomically, so concurrent requests with the same token can't both succeed
+	_, err = p.tokenStore.Consume(tokenHash)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	encodedPassword, err := p.passwordEncoder.Encode(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = p.userStore.ChangePassword(resetToken.Subject, encodedPassword)
+	if err != nil {
+		return err
+	}
+
+	err = p.tokenStore.DeleteBySubject(resetToken.Subject)
+	if err != nil {
+		return err
+	}
+
+	if p.refreshTokenStore != nil {
+		err = RevokeAllRefreshTokens(resetToken.Subject, p.refreshTokenStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ************************************************************************ //
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type CompletePasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// RequestHandler starts a password reset. It answers 202 whether or not the user exists, and does so before
+// the user is looked up, so the response time doesn't reveal it either. The reset is sent by a fixed number of
+// background workers, see WithWorkers, and requests are limited per username and client IP, see
+// WithRequestThrottle.
+func (p *PasswordReset) RequestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resetReq PasswordResetRequest
+		if !decodeJSONRequest(w, r, &resetReq) {
+			return
+		}
+
+		if resetReq.Username == "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "Username is required")
+			return
+		}
+
+		if p.requestThrottle != nil {
+			clientIP := ObtainClientIP(r)
+			err := p.requestThrottle.Reserve(resetReq.Username, clientIP)
+			var throttledErr *LoginThrottledError
+			if errors.As(err, &throttledErr) {
+				throttledErr.setRetryAfter(w)
+				writeError(w, http.StatusTooManyRequests, "too_many_requests", "Too many password reset requests, try again later")
+				return
+			}
+			if err == nil {
+				err = p.requestThrottle.RecordFailure(resetReq.Username, clientIP)
+			}
+			if err != nil {
+				log.Printf("Failed to throttle password reset request: %v", err)
+				writeError(w, http.StatusInternalServerError, "server_error", "Failed to request password reset")
+				return
+			}
+		}
+
+		p.startWorkers.Do(p.runWorkers)
+		select {
+		case p.queue <- queuedResetRequest{ctx: context.WithoutCancel(r.Context()), username: resetReq.Username}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, "server_busy", "Too many pending password reset requests, try again later")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// runWorkers starts the workers sending the resets queued by RequestHandler.
+func (p *PasswordReset) runWorkers() {
+	for i := 0; i < max(p.workers, 1); i++ {
+		go func() {
+			for request := range p.queue {
+				err := p.RequestReset(request.ctx, request.username)
+				if err != nil {
+					log.Printf("Failed to request password reset: %v", err)
+				}
+			}
+		}()
+	}
+}
+
+// CompleteHandler sets the new password of the user a reset token was issued to.
+func (p *PasswordReset) CompleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var completeReq CompletePasswordResetRequest
+		if !decodeJSONRequest(w, r, &completeReq) {
+			return
+		}
+
+		err := p.CompleteReset(r.Context(), completeReq.Token, completeReq.NewPassword)
+		if err != nil {
+			writePasswordChangeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
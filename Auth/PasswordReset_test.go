@@ -0,0 +1,182 @@
+package Auth
+
+import (
+	"context"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompleteResetKeepsTokenWhenPasswordIsRejected(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice", encodePassword(t, "old password"))
+	notifier := NewRecordingNotifier()
+	passwordReset := NewPasswordReset(userStore, Store.NewInMemoryPasswordResetTokenStore(), notifier, nil)
+
+	err := passwordReset.RequestReset(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("RequestReset: %v", err)
+	}
+	first, _ := notifier.Last("alice")
+	err = passwordReset.RequestReset(context.Background(), "alice")
+	if err != nil {
+		t.Fatalf("RequestReset: %v", err)
+	}
+	second, _ := notifier.Last("alice")
+
+	var policyErr *PasswordPolicyError
+	err = passwordReset.CompleteReset(context.Background(), second.Token, "short")
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("CompleteReset with a short password: got %v, want a *PasswordPolicyError", err)
+	}
+
+	err = passwordReset.CompleteReset(context.Background(), second.Token, "a new password")
+	if err != nil {
+		t.Fatalf("CompleteReset after a rejected password: %v", err)
+	}
+
+	err = passwordReset.CompleteReset(context.Background(), second.Token, "another password")
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("CompleteReset with a used token: got %v, want %v", err, ErrInvalidResetToken)
+	}
+	err = passwordReset.CompleteReset(context.Background(), first.Token, "another password")
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("CompleteReset with an earlier token: got %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestRequestHandlerSendsResetInBackground(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice", encodePassword(t, "old password"))
+	notifier := NewRecordingNotifier()
+	handler := NewPasswordReset(userStore, Store.NewInMemoryPasswordResetTokenStore(), notifier, nil).RequestHandler()
+
+	for _, username := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"username":"`+username+`"}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%s: got status %d, want %d", username, w.Code, http.StatusAccepted)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := notifier.Last("alice"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no reset notification was sent to alice")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := notifier.Last("bob"); ok {
+		t.Fatal("a reset notification was sent to an unknown user")
+	}
+}
+
+func requestReset(handler http.Handler, username, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"username":"`+username+`"}`))
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestHandlerLimitsRequests(t *testing.T) {
+	throttle := NewLoginThrottle(Store.NewInMemoryLoginAttemptStore()).WithThresholds(2, 3, time.Hour).WithDelays(0, 0)
+	handler := NewPasswordReset(Store.NewInMemoryUserStore(), Store.NewInMemoryPasswordResetTokenStore(), NewRecordingNotifier(), nil).
+		WithRequestThrottle(throttle).
+		RequestHandler()
+
+	tests := []struct {
+		username   string
+		remoteAddr string
+		status     int
+	}{
+		{"alice@example.com", "192.0.2.1:1234", http.StatusAccepted},
+		{"Alice@example.com", "192.0.2.2:1234", http.StatusAccepted},
+		{"alice@example.com", "192.0.2.3:1234", http.StatusTooManyRequests}, // The same mailbox from anywhere
+		{"bob@example.com", "192.0.2.1:1234", http.StatusAccepted},
+		{"carol@example.com", "192.0.2.1:1234", http.StatusAccepted},
+		{"dave@example.com", "192.0.2.1:1234", http.StatusTooManyRequests}, // Many mailboxes from one IP
+		{"dave@example.com", "192.0.2.4:1234", http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		w := requestReset(handler, tt.username, tt.remoteAddr)
+		if w.Code != tt.status {
+			t.Fatalf("%s from %s: got status %d, want %d", tt.username, tt.remoteAddr, w.Code, tt.status)
+		}
+		if tt.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s from %s: no Retry-After header", tt.username, tt.remoteAddr)
+		}
+	}
+}
+
+// blockingNotifier holds every notification until release is closed.
+type blockingNotifier struct {
+	release  chan struct{}
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+	sent     atomic.Int32
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, notification Notification) error {
+	inFlight := n.inFlight.Add(1)
+	if inFlight > n.maxSeen.Load() {
+		n.maxSeen.Store(inFlight)
+	}
+	<-n.release
+	n.inFlight.Add(-1)
+	n.sent.Add(1)
+	return nil
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestHandlerBoundsBackgroundWork(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice", encodePassword(t, "old password"))
+	notifier := &blockingNotifier{release: make(chan struct{})}
+	handler := NewPasswordReset(userStore, Store.NewInMemoryPasswordResetTokenStore(), notifier, nil).
+		WithRequestThrottle(nil).
+		WithWorkers(2, 1).
+		RequestHandler()
+
+	for i := int32(1); i <= 2; i++ {
+		if w := requestReset(handler, "alice", "192.0.2.1:1234"); w.Code != http.StatusAccepted {
+			t.Fatalf("request %d: got status %d, want %d", i, w.Code, http.StatusAccepted)
+		}
+		waitFor(t, func() bool { return notifier.inFlight.Load() == i })
+	}
+
+	if w := requestReset(handler, "alice", "192.0.2.1:1234"); w.Code != http.StatusAccepted {
+		t.Fatalf("queued request: got status %d, want %d", w.Code, http.StatusAccepted)
+	}
+	w := requestReset(handler, "alice", "192.0.2.1:1234")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("request beyond the queue: got status %d, want %d with Retry-After", w.Code, http.StatusServiceUnavailable)
+	}
+
+	close(notifier.release)
+	waitFor(t, func() bool { return notifier.sent.Load() == 3 })
+	if maxSeen := notifier.maxSeen.Load(); maxSeen > 2 {
+		t.Fatalf("%d resets were sent at once, want at most 2", maxSeen)
+	}
+}
@@ -0,0 +1,153 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"log"
+	"net/http"
+)
+
+// RevocationHandler serves the RFC 7009 token revocation endpoint.
+// Refresh tokens are removed from the refresh token store; access tokens are either deleted from the
+// opaque access token store or, for JWTs, added to the jti denylist.
+//
+// A client may only revoke the tokens issued to it with WithClientID. Tokens issued without a client are
+// refused too, unless WithClientlessTokens allows every authenticated client to revoke them.
+type RevocationHandler struct {
+	clientAuthenticator ClientAuthenticator
+	refreshTokenStore   Store.RefreshTokenStore
+	accessTokenStore    Store.AccessTokenStore
+	secret              []byte
+	denylist            Store.TokenDenylist
+	clientlessTokens    bool
+}
+
+func NewRevocationHandler(clientAuthenticator ClientAuthenticator, refreshTokenStore Store.RefreshTokenStore) *RevocationHandler {
+	return &RevocationHandler{
+		clientAuthenticator: clientAuthenticator,
+		refreshTokenStore:   refreshTokenStore,
+	}
+}
+
+// WithAccessTokenStore enables revocation of opaque access tokens.
+func (h *RevocationHandler) WithAccessTokenStore(accessTokenStore Store.AccessTokenStore) *RevocationHandler {
+	h.accessTokenStore = accessTokenStore
+	return h
+}
+
+// WithJWTDenylist enables revocation of JWT access tokens signed with secret.
+func (h *RevocationHandler) WithJWTDenylist(secret []byte, denylist Store.TokenDenylist) *RevocationHandler {
+	h.secret = secret
+	h.denylist = denylist
+	return h
+}
+
+// WithClientlessTokens lets any authenticated client revoke tokens issued without a client ID, e.g. when a
+// single first-party client is the only one there is.
+func (h *RevocationHandler) WithClientlessTokens() *RevocationHandler {
+	h.clientlessTokens = true
+	return h
+}
+
+func (h *RevocationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "revocation requires POST")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	clientID, err := h.clientAuthenticator.AuthenticateClient(r)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "missing token parameter")
+		return
+	}
+
+	// The hint only decides the lookup order; an unknown hint is ignored as allowed by RFC 7009
+	revokers := []func(token, clientID string) (bool, error){h.revokeRefreshToken, h.revokeAccessToken}
+	if r.PostForm.Get("token_type_hint") == "access_token" {
+		revokers = []func(token, clientID string) (bool, error){h.revokeAccessToken, h.revokeRefreshToken}
+	}
+
+	for _, revoke := range revokers {
+		found, err := revoke(token, clientID)
+		if errors.Is(err, errTokenNotOwnedByClient) {
+			writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "token was not issued to this client")
+			return
+		}
+		if err != nil {
+			log.Printf("Token revocation failed: %v", err)
+			writeOAuthError(w, http.StatusServiceUnavailable, "server_error", "")
+			return
+		}
+		if found {
+			break
+		}
+	}
+
+	// Invalid or unknown tokens are answered with 200 too, the client has nothing left to do
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *RevocationHandler) revokeRefreshToken(token, clientID string) (bool, error) {
+	record, err := findRefreshToken(token, h.refreshTokenStore)
+	if err != nil {
+		return false, nil
+	}
+	err = h.checkOwner(record.ClientID, clientID)
+	if err != nil {
+		return true, err
+	}
+
+	return true, RevokeRefreshToken(token, h.refreshTokenStore)
+}
+
+func (h *RevocationHandler) revokeAccessToken(token, clientID string) (bool, error) {
+	if h.accessTokenStore != nil {
+		record, err := h.accessTokenStore.Find(token)
+		if err == nil && record != nil {
+			err = h.checkOwner(record.ClientID, clientID)
+			if err != nil {
+				return true, err
+			}
+			return true, h.accessTokenStore.Delete(token)
+		}
+	}
+
+	if h.denylist != nil {
+		claims, err := parseAccessToken(token, h.secret)
+		if err != nil {
+			return false, nil
+		}
+		err = h.checkOwner(claims.ClientID, clientID)
+		if err != nil {
+			return true, err
+		}
+		return true, RevokeJWT(token, h.secret, h.denylist)
+	}
+
+	return false, nil
+}
+
+// checkOwner returns errTokenNotOwnedByClient unless clientID may revoke a token issued to tokenClientID.
+func (h *RevocationHandler) checkOwner(tokenClientID, clientID string) error {
+	if tokenClientID == "" && h.clientlessTokens {
+		return nil
+	}
+	if tokenClientID != clientID {
+		return errTokenNotOwnedByClient
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package Auth
+
+import (
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRevocationHandler() (*RevocationHandler, *Store.InMemoryRefreshTokenStore, *Store.InMemoryAccessTokenStore, *Store.InMemoryTokenDenylist) {
+	clientAuthenticator := NewInMemoryClientAuthenticator()
+	clientAuthenticator.AddClient("app", "app secret")
+	clientAuthenticator.AddClient("other", "other secret")
+
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	accessTokenStore := Store.NewInMemoryAccessTokenStore()
+	denylist := Store.NewInMemoryTokenDenylist()
+	handler := NewRevocationHandler(clientAuthenticator, refreshTokenStore).
+		WithAccessTokenStore(accessTokenStore).
+		WithJWTDenylist(testSecret, denylist)
+
+	return handler, refreshTokenStore, accessTokenStore, denylist
+}
+
+func revoke(handler http.Handler, clientID, clientSecret, token, tokenTypeHint string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {token}}
+	if tokenTypeHint != "" {
+		form.Set("token_type_hint", tokenTypeHint)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRevocationHandlerOnlyRevokesTokensOfTheClient(t *testing.T) {
+	handler, refreshTokenStore, accessTokenStore, denylist := newTestRevocationHandler()
+
+	jwtToken, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore, WithClientID("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opaqueToken, _, err := GenerateOpaqueToken("alice", time.Minute, accessTokenStore, refreshTokenStore, WithClientID("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []struct{ name, token, hint string }{
+		{"refresh token", refreshToken, ""},
+		{"JWT access token", jwtToken, "access_token"},
+		{"opaque access token", opaqueToken, "access_token"},
+	} {
+		w := revoke(handler, "other", "other secret", token.token, token.hint)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unauthorized_client") {
+			t.Fatalf("%s revoked by another client: got status %d: %s", token.name, w.Code, w.Body)
+		}
+	}
+	if _, err := refreshTokenStore.FindSubject(refreshToken); err != nil {
+		t.Fatal("refresh token was revoked by another client")
+	}
+	if record, err := accessTokenStore.Find(opaqueToken); err != nil || record == nil {
+		t.Fatal("opaque access token was revoked by another client")
+	}
+
+	for _, token := range []struct{ name, token, hint string }{
+		{"refresh token", refreshToken, ""},
+		{"JWT access token", jwtToken, "access_token"},
+		{"opaque access token", opaqueToken, "access_token"},
+	} {
+		w := revoke(handler, "app", "app secret", token.token, token.hint)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s revoked by its client: got status %d: %s", token.name, w.Code, w.Body)
+		}
+	}
+	if _, err := refreshTokenStore.FindSubject(refreshToken); err == nil {
+		t.Fatal("refresh token is still valid after revocation")
+	}
+	if record, err := accessTokenStore.Find(opaqueToken); err == nil && record != nil {
+		t.Fatal("opaque access token is still valid after revocation")
+	}
+	claims, err := ParseJWT(jwtToken, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := denylist.IsRevoked(claims.Id); !revoked {
+		t.Fatal("JWT access token is not on the denylist after revocation")
+	}
+}
+
+func TestRevocationHandlerClientlessTokens(t *testing.T) {
+	handler, refreshTokenStore, _, _ := newTestRevocationHandler()
+
+	_, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := revoke(handler, "app", "app secret", refreshToken, "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("token without a client: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if _, err := refreshTokenStore.FindSubject(refreshToken); err != nil {
+		t.Fatal("token without a client was revoked")
+	}
+
+	w = revoke(handler.WithClientlessTokens(), "app", "app secret", refreshToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("token without a client WithClientlessTokens: got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if _, err := refreshTokenStore.FindSubject(refreshToken); err == nil {
+		t.Fatal("token without a client is still valid WithClientlessTokens")
+	}
+}
+
+func TestRevocationHandlerRejectsUnauthenticatedClientsAndAcceptsUnknownTokens(t *testing.T) {
+	handler, _, _, _ := newTestRevocationHandler()
+
+	if w := revoke(handler, "app", "wrong secret", "token", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong client secret: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := revoke(handler, "app", "app secret", "unknown token", ""); w.Code != http.StatusOK {
+		t.Fatalf("unknown token: got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
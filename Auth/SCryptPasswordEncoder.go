@@ -0,0 +1,94 @@
+package Auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SCryptPasswordEncoder hashes passwords with scrypt, stored as $scrypt$ln=<log2 N>,r=<r>,p=<p>$salt$hash.
+// The defaults follow the OWASP recommendation of N=2^17, r=8 and p=1.
+type SCryptPasswordEncoder struct {
+	logN       int
+	r          int
+	p          int
+	saltLength int
+	keyLength  int
+}
+
+func NewSCryptPasswordEncoder() *SCryptPasswordEncoder {
+	return &SCryptPasswordEncoder{
+		logN:       17,
+		r:          8,
+		p:          1,
+		saltLength: 16,
+		keyLength:  32,
+	}
+}
+
+// WithParameters sets the CPU/memory cost as log2(N), the block size r and the parallelism p.
+func (e *SCryptPasswordEncoder) WithParameters(logN, r, p int) *SCryptPasswordEncoder {
+	e.logN = logN
+	e.r = r
+	e.p = p
+	return e
+}
+
+func (e *SCryptPasswordEncoder) Encode(rawPassword string) (string, error) {
+	salt, err := randomSalt(e.saltLength)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(rawPassword), salt, 1<<e.logN, e.r, e.p, e.keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	params := fmt.Sprintf("ln=%d,r=%d,p=%d", e.logN, e.r, e.p)
+	return formatPHC("scrypt", "", params, salt, hash), nil
+}
+
+func (e *SCryptPasswordEncoder) Matches(rawPassword, encodedPassword string) (bool, error) {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	if phc.id != "scrypt" {
+		return false, fmt.Errorf("%w: not an scrypt hash", ErrMalformedPasswordHash)
+	}
+
+	logN, err := phc.intParam("ln")
+	if err != nil || logN > 30 {
+		return false, fmt.Errorf("%w: invalid parameter ln", ErrMalformedPasswordHash)
+	}
+	r, err := phc.intParam("r")
+	if err != nil {
+		return false, err
+	}
+	p, err := phc.intParam("p")
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := scrypt.Key([]byte(rawPassword), phc.salt, 1<<logN, r, p, len(phc.hash))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(hash, phc.hash) == 1, nil
+}
+
+func (e *SCryptPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	phc, err := parsePHC(encodedPassword)
+	if err != nil || phc.id != "scrypt" {
+		return false
+	}
+
+	logN, _ := phc.intParam("ln")
+	r, _ := phc.intParam("r")
+	p, _ := phc.intParam("p")
+
+	return logN < e.logN || r < e.r || p < e.p || len(phc.salt) < e.saltLength || len(phc.hash) < e.keyLength
+}
@@ -0,0 +1,64 @@
+package Auth
+
+import (
+	"context"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"time"
+)
+
+// Principal describes who a request was authenticated as.
+type Principal struct {
+	Subject string
+	Session *model.Session // set only when authenticated through SessionAuth
+
+	// PasswordChangeOnly marks a user who must change their password; AuthChain lets such a
+	// principal reach nothing but the password change path.
+	PasswordChangeOnly bool
+
+	// IssuedAt is when the access token the request was authenticated with was issued, zero otherwise.
+	// AuthChain rejects tokens issued before the user's password last changed.
+	IssuedAt time.Time
+}
+
+// SecurityContext holds the authentication state of a single request.
+// AuthMiddleware attaches an empty one before running the chain, and the successful AuthMethod fills it in.
+type SecurityContext struct {
+	principal *Principal
+}
+
+type securityContextKey struct{}
+
+func (sc *SecurityContext) Principal() *Principal {
+	return sc.principal
+}
+
+func (sc *SecurityContext) SetPrincipal(principal *Principal) {
+	sc.principal = principal
+}
+
+// SecurityContextFromRequest returns the request's SecurityContext, or nil outside of AuthMiddleware.
+func SecurityContextFromRequest(r *http.Request) *SecurityContext {
+	sc, _ := r.Context().Value(securityContextKey{}).(*SecurityContext)
+	return sc
+}
+
+// PrincipalFromRequest returns the authenticated principal, or nil if the request is unauthenticated.
+func PrincipalFromRequest(r *http.Request) *Principal {
+	sc := SecurityContextFromRequest(r)
+	if sc == nil {
+		return nil
+	}
+	return sc.principal
+}
+
+func withSecurityContext(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), securityContextKey{}, &SecurityContext{}))
+}
+
+// setPrincipal records the principal if the request runs inside AuthMiddleware.
+func setPrincipal(r *http.Request, principal *Principal) {
+	if sc := SecurityContextFromRequest(r); sc != nil {
+		sc.SetPrincipal(principal)
+	}
+}
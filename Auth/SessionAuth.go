@@ -0,0 +1,158 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrSessionExpired = errors.New("session expired")
+)
+
+const passwordChangeOnlyAttribute = "password_change_only"
+
+// SessionAuth authenticates requests through a server-side session referenced by a cookie.
+// The cookie uses the __Host- prefix, so it is only sent over HTTPS, to this exact host, for every path.
+type SessionAuth struct {
+	sessionStore    Store.SessionStore
+	cookieName      string
+	sameSite        http.SameSite
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+}
+
+func NewSessionAuth(sessionStore Store.SessionStore) *SessionAuth {
+	return &SessionAuth{
+		sessionStore:    sessionStore,
+		cookieName:      "__Host-session",
+		sameSite:        http.SameSiteLaxMode,
+		idleTimeout:     30 * time.Minute,
+		absoluteTimeout: 8 * time.Hour,
+	}
+}
+
+// WithTimeouts sets how long a session may stay unused and how long it may live at all.
+func (s *SessionAuth) WithTimeouts(idleTimeout, absoluteTimeout time.Duration) *SessionAuth {
+	s.idleTimeout = idleTimeout
+	s.absoluteTimeout = absoluteTimeout
+	return s
+}
+
+// WithSameSite sets the SameSite attribute of the session cookie, Lax by default.
+func (s *SessionAuth) WithSameSite(sameSite http.SameSite) *SessionAuth {
+	s.sameSite = sameSite
+	return s
+}
+
+// EstablishSession starts a new session for user. Any session the client already had is destroyed, and the new
+// one gets a fresh ID, so an attacker-planted session ID can never become authenticated (session fixation).
+func (s *SessionAuth) EstablishSession(w http.ResponseWriter, r *http.Request, user *model.User) error {
+	if cookie, err := r.Cookie(s.cookieName); err == nil {
+		err := s.sessionStore.Delete(cookie.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	sessionID, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		ID:             sessionID,
+		Subject:        user.Username,
+		CreatedAt:      now,
+		LastAccessedAt: now,
+		ExpiresAt:      s.expiresAt(now, now),
+		Attributes:     make(map[string]string),
+	}
+	if user.MustChangePassword {
+		session.Attributes[passwordChangeOnlyAttribute] = "true"
+	}
+
+	err = s.sessionStore.Save(session)
+	if err != nil {
+		return err
+	}
+
+	s.setCookie(w, sessionID, 0)
+	return nil
+}
+
+// EndSession destroys the current session and clears the cookie.
+func (s *SessionAuth) EndSession(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return nil
+	}
+
+	s.setCookie(w, "", -1)
+	return s.sessionStore.Delete(cookie.Value)
+}
+
+func (s *SessionAuth) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
+	cookie, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return false, errors.New("missing session cookie")
+	}
+
+	session, err := s.sessionStore.Find(cookie.Value)
+	if err != nil || session == nil {
+		return false, errors.New("unknown session")
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastAccessedAt) > s.idleTimeout || now.Sub(session.CreatedAt) > s.absoluteTimeout {
+		err := s.sessionStore.Delete(session.ID)
+		if err != nil {
+			return false, err
+		}
+		return false, ErrSessionExpired
+	}
+
+	// Touch instead of Save, which would bring back a session that was deleted meanwhile
+	session.LastAccessedAt = now
+	session.ExpiresAt = s.expiresAt(session.CreatedAt, now)
+	err = s.sessionStore.Touch(session.ID, session.LastAccessedAt, session.ExpiresAt)
+	if errors.Is(err, Store.ErrSessionNotFound) {
+		return false, errors.New("unknown session")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	setPrincipal(r, &Principal{
+		Subject:            session.Subject,
+		Session:            session,
+		PasswordChangeOnly: session.Attributes[passwordChangeOnlyAttribute] == "true",
+	})
+
+	return true, nil
+}
+
+// expiresAt returns when a session created and last accessed at the given times runs into either timeout.
+func (s *SessionAuth) expiresAt(createdAt, lastAccessedAt time.Time) time.Time {
+	idleExpiry := lastAccessedAt.Add(s.idleTimeout)
+	absoluteExpiry := createdAt.Add(s.absoluteTimeout)
+	if idleExpiry.Before(absoluteExpiry) {
+		return idleExpiry
+	}
+	return absoluteExpiry
+}
+
+func (s *SessionAuth) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: s.sameSite,
+	})
+}
@@ -0,0 +1,189 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "__Host-session" {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie was set")
+	return nil
+}
+
+func authenticateSession(sessionAuth *SessionAuth, sessionID string) (*Principal, error) {
+	req := withSecurityContext(httptest.NewRequest("GET", "/", nil))
+	req.AddCookie(&http.Cookie{Name: "__Host-session", Value: sessionID})
+
+	_, err := sessionAuth.Authenticate(httptest.NewRecorder(), req)
+	return PrincipalFromRequest(req), err
+}
+
+func TestSessionAuthEstablishSession(t *testing.T) {
+	sessionStore := Store.NewInMemorySessionStore()
+	sessionAuth := NewSessionAuth(sessionStore)
+	plantedID := newTestSession(t, sessionStore, "attacker")
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.AddCookie(&http.Cookie{Name: "__Host-session", Value: plantedID})
+	w := httptest.NewRecorder()
+	err := sessionAuth.EstablishSession(w, req, &model.User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := sessionCookie(t, w)
+	// The __Host- prefix is only honoured by browsers for Secure cookies without a Domain and with Path=/
+	if !cookie.Secure || cookie.Path != "/" || cookie.Domain != "" || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("got cookie %+v, want a Secure, HttpOnly, SameSite=Lax, host-only cookie for /", cookie)
+	}
+
+	if cookie.Value == plantedID {
+		t.Fatal("the session kept the ID the client sent before logging in")
+	}
+	if _, err := sessionStore.Find(plantedID); !errors.Is(err, Store.ErrSessionNotFound) {
+		t.Fatalf("finding the previous session: got error %v, want %v", err, Store.ErrSessionNotFound)
+	}
+
+	principal, err := authenticateSession(sessionAuth, cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Subject != "alice" || principal.Session == nil || principal.Session.ID != cookie.Value {
+		t.Fatalf("got principal %+v, want alice with the new session", principal)
+	}
+}
+
+func TestSessionAuthSameSite(t *testing.T) {
+	sessionAuth := NewSessionAuth(Store.NewInMemorySessionStore()).WithSameSite(http.SameSiteStrictMode)
+
+	w := httptest.NewRecorder()
+	err := sessionAuth.EstablishSession(w, httptest.NewRequest("POST", "/login", nil), &model.User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie := sessionCookie(t, w); cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("got SameSite %v, want %v", cookie.SameSite, http.SameSiteStrictMode)
+	}
+}
+
+func TestSessionAuthTimeouts(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name           string
+		createdAt      time.Time
+		lastAccessedAt time.Time
+		wantErr        error
+	}{
+		{"active", now.Add(-time.Hour), now.Add(-time.Minute), nil},
+		{"idle too long", now.Add(-time.Hour), now.Add(-31 * time.Minute), ErrSessionExpired},
+		{"alive too long", now.Add(-9 * time.Hour), now.Add(-time.Minute), ErrSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionStore := Store.NewInMemorySessionStore()
+			sessionAuth := NewSessionAuth(sessionStore).WithTimeouts(30*time.Minute, 8*time.Hour)
+			err := sessionStore.Save(&model.Session{
+				ID:             "session",
+				Subject:        "alice",
+				CreatedAt:      tt.createdAt,
+				LastAccessedAt: tt.lastAccessedAt,
+				ExpiresAt:      now.Add(time.Hour), // Left to SessionAuth rather than the store
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = authenticateSession(sessionAuth, "session")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			session, findErr := sessionStore.Find("session")
+			if tt.wantErr != nil {
+				if !errors.Is(findErr, Store.ErrSessionNotFound) {
+					t.Fatalf("finding the expired session: got error %v, want %v", findErr, Store.ErrSessionNotFound)
+				}
+				return
+			}
+
+			if findErr != nil {
+				t.Fatal(findErr)
+			}
+			if !session.LastAccessedAt.After(tt.lastAccessedAt) {
+				t.Fatalf("LastAccessedAt stayed %v, want it touched", session.LastAccessedAt)
+			}
+			if want := session.LastAccessedAt.Add(30 * time.Minute); !session.ExpiresAt.Equal(want) {
+				t.Fatalf("got ExpiresAt %v, want %v", session.ExpiresAt, want)
+			}
+		})
+	}
+}
+
+// loggingOutSessionStore deletes every session right after it was found, like a concurrent logout.
+type loggingOutSessionStore struct {
+	*Store.InMemorySessionStore
+}
+
+func (s loggingOutSessionStore) Find(id string) (*model.Session, error) {
+	session, err := s.InMemorySessionStore.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	return session, s.InMemorySessionStore.Delete(id)
+}
+
+func TestSessionAuthDoesNotTouchDeletedSession(t *testing.T) {
+	sessionStore := loggingOutSessionStore{Store.NewInMemorySessionStore()}
+	sessionID := newTestSession(t, sessionStore, "alice")
+
+	principal, err := authenticateSession(NewSessionAuth(sessionStore), sessionID)
+	if err == nil || principal != nil {
+		t.Fatalf("got principal %+v and error %v, want the deleted session rejected", principal, err)
+	}
+
+	if _, err := sessionStore.InMemorySessionStore.Find(sessionID); !errors.Is(err, Store.ErrSessionNotFound) {
+		t.Fatalf("finding the deleted session: got error %v, want %v", err, Store.ErrSessionNotFound)
+	}
+}
+
+func TestSessionAuthEndSession(t *testing.T) {
+	sessionStore := Store.NewInMemorySessionStore()
+	sessionAuth := NewSessionAuth(sessionStore)
+	sessionID := newTestSession(t, sessionStore, "alice")
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "__Host-session", Value: sessionID})
+	w := httptest.NewRecorder()
+	err := sessionAuth.EndSession(w, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie := sessionCookie(t, w); cookie.Value != "" || cookie.MaxAge >= 0 {
+		t.Fatalf("got cookie %+v, want it cleared", cookie)
+	}
+
+	if _, err := authenticateSession(sessionAuth, sessionID); err == nil {
+		t.Fatal("the session still authenticates after logging out")
+	}
+
+	// Logging out without a session is a no-op
+	err = sessionAuth.EndSession(httptest.NewRecorder(), httptest.NewRequest("POST", "/logout", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
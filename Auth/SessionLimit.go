@@ -0,0 +1,181 @@
+package Auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"sync"
+)
+
+var (
+	ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+)
+
+// SessionLimitPolicy decides what happens when a subject already has the maximum number of sessions.
+type SessionLimitPolicy int
+
+const (
+	// RejectNewSession refuses to issue a new token pair.
+	RejectNewSession SessionLimitPolicy = iota
+	// EvictOldestSession revokes the oldest refresh tokens to make room for the new one.
+	// Access tokens already issued to the evicted sessions stay valid until they expire, as refresh tokens
+	// don't record them; keep their lifetime short or revoke them yourself in an OnLimitReached listener.
+	EvictOldestSession
+)
+
+func (p SessionLimitPolicy) String() string {
+	switch p {
+	case RejectNewSession:
+		return "RejectNewSession"
+	case EvictOldestSession:
+		return "EvictOldestSession"
+	default:
+		return fmt.Sprintf("SessionLimitPolicy(%d)", int(p))
+	}
+}
+
+// SessionLimitEvent is emitted whenever a subject hits the session limit.
+type SessionLimitEvent struct {
+	Subject        string
+	Policy         SessionLimitPolicy
+	ActiveSessions int
+	EvictedTokens  []string // refresh tokens revoked by EvictOldestSession
+}
+
+// SessionLimiter caps the number of active sessions, i.e. refresh tokens, a subject can hold.
+// A maxSessions of zero or less disables the limit.
+type SessionLimiter struct {
+	mu           sync.Mutex // guards subjectLocks and listeners
+	subjectLocks map[string]*subjectLock
+	maxSessions  int
+	policy       SessionLimitPolicy
+	listeners    []func(SessionLimitEvent)
+}
+
+// subjectLock serializes issuing sessions of one subject; refs counts its holders and waiters.
+type subjectLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewSessionLimiter(maxSessions int, policy SessionLimitPolicy) *SessionLimiter {
+	return &SessionLimiter{
+		subjectLocks: make(map[string]*subjectLock),
+		maxSessions:  maxSessions,
+		policy:       policy,
+		listeners:    make([]func(SessionLimitEvent), 0),
+	}
+}
+
+// OnLimitReached registers a listener that is called every time the limit is reached.
+// Listeners run after the new session was issued or rejected, so they may issue or revoke tokens themselves.
+func (l *SessionLimiter) OnLimitReached(listener func(SessionLimitEvent)) *SessionLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.listeners = append(l.listeners, listener)
+	return l
+}
+
+// issue runs issueTokens, which must save the new refresh token, after making room for it. Sessions of the same
+// subject are issued one at a time, while different subjects don't wait for each other. A nil limiter just
+// runs issueTokens.
+func (l *SessionLimiter) issue(subject string, refreshTokenStore Store.RefreshTokenStore, issueTokens func() (string, string, error)) (string, string, error) {
+	if l == nil {
+		return issueTokens()
+	}
+
+	unlock := l.lockSubject(subject)
+	event, err := l.enforce(subject, refreshTokenStore)
+
+	var accessToken, refreshToken string
+	if err == nil {
+		accessToken, refreshToken, err = issueTokens()
+	}
+	unlock()
+
+	if event != nil {
+		l.emit(*event)
+	}
+
+	return accessToken, refreshToken, err
+}
+
+func (l *SessionLimiter) lockSubject(subject string) func() {
+	l.mu.Lock()
+	lock, ok := l.subjectLocks[subject]
+	if !ok {
+		lock = &subjectLock{}
+		l.subjectLocks[subject] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.subjectLocks, subject)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// enforce makes room for one more session of subject, or fails with ErrSessionLimitReached. It returns the
+// event to emit if the limit was reached. The caller must hold the subject's lock until the new refresh token
+// has been saved.
+func (l *SessionLimiter) enforce(subject string, refreshTokenStore Store.RefreshTokenStore) (*SessionLimitEvent, error) {
+	if l.maxSessions <= 0 {
+		return nil, nil // No limit configured
+	}
+
+	indexedStore, ok := refreshTokenStore.(Store.SubjectIndexedRefreshTokenStore)
+	if !ok {
+		return nil, fmt.Errorf("session limit requires a refresh token store implementing SubjectIndexedRefreshTokenStore, got %T", refreshTokenStore)
+	}
+
+	records, err := indexedStore.FindBySubject(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) < l.maxSessions {
+		return nil, nil
+	}
+
+	event := &SessionLimitEvent{
+		Subject:        subject,
+		Policy:         l.policy,
+		ActiveSessions: len(records),
+	}
+
+	if l.policy == RejectNewSession {
+		return event, ErrSessionLimitReached
+	}
+
+	// Evict the oldest sessions so that exactly maxSessions remain after the new one is saved
+	evictCount := len(records) - l.maxSessions + 1
+	for _, record := range records[:evictCount] {
+		err := indexedStore.Delete(record.Token)
+		if err != nil {
+			return nil, err
+		}
+		event.EvictedTokens = append(event.EvictedTokens, record.Token)
+	}
+
+	return event, nil
+}
+
+func (l *SessionLimiter) emit(event SessionLimitEvent) {
+	l.mu.Lock()
+	listeners := append([]func(SessionLimitEvent){}, l.listeners...)
+	l.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,186 @@
+package Auth
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionLimiterRejectsNewSessions(t *testing.T) {
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	var events []SessionLimitEvent
+	limiter := NewSessionLimiter(2, RejectNewSession).OnLimitReached(func(event SessionLimitEvent) {
+		events = append(events, event)
+	})
+
+	for i := 0; i < 2; i++ {
+		_, _, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+		if err != nil {
+			t.Fatalf("session %d: %v", i+1, err)
+		}
+	}
+	if len(events) != 0 {
+		t.Fatalf("got events %v below the limit", events)
+	}
+
+	_, _, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+	if !errors.Is(err, ErrSessionLimitReached) {
+		t.Fatalf("third session: got error %v, want %v", err, ErrSessionLimitReached)
+	}
+
+	records, _ := refreshTokenStore.FindBySubject("alice")
+	if len(records) != 2 {
+		t.Fatalf("got %d refresh tokens, want 2", len(records))
+	}
+
+	want := SessionLimitEvent{Subject: "alice", Policy: RejectNewSession, ActiveSessions: 2}
+	if len(events) != 1 || events[0].Subject != want.Subject || events[0].Policy != want.Policy ||
+		events[0].ActiveSessions != want.ActiveSessions || len(events[0].EvictedTokens) != 0 {
+		t.Fatalf("got events %+v, want %+v", events, want)
+	}
+
+	// Other subjects have limits of their own
+	_, _, err = GenerateJWT("bob", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionLimiterEvictsOldestSessions(t *testing.T) {
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	var events []SessionLimitEvent
+	limiter := NewSessionLimiter(2, EvictOldestSession).OnLimitReached(func(event SessionLimitEvent) {
+		events = append(events, event)
+	})
+
+	var refreshTokens []string
+	for i := 0; i < 4; i++ {
+		_, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+		if err != nil {
+			t.Fatalf("session %d: %v", i+1, err)
+		}
+		refreshTokens = append(refreshTokens, refreshToken)
+	}
+
+	records, _ := refreshTokenStore.FindBySubject("alice")
+	remaining := make([]string, 0, len(records))
+	for _, record := range records {
+		remaining = append(remaining, record.Token)
+	}
+	if !slices.Equal(remaining, refreshTokens[2:]) {
+		t.Fatalf("got refresh tokens %v, want the two newest %v", remaining, refreshTokens[2:])
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for i, event := range events {
+		if event.Policy != EvictOldestSession || event.ActiveSessions != 2 || !slices.Equal(event.EvictedTokens, refreshTokens[i:i+1]) {
+			t.Fatalf("event %d: got %+v, want refresh token %q evicted", i, event, refreshTokens[i])
+		}
+	}
+
+	// Evicted refresh tokens can't be used any more
+	_, _, err := RefreshJWT(refreshTokens[0], testSecret, refreshTokenStore)
+	if err == nil {
+		t.Fatal("an evicted refresh token was refreshed")
+	}
+}
+
+func TestSessionLimiterListenersMayIssueTokens(t *testing.T) {
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	limiter := NewSessionLimiter(1, RejectNewSession)
+	limiter.OnLimitReached(func(event SessionLimitEvent) {
+		// Listeners run unlocked, so issuing a session of another subject doesn't deadlock
+		_, _, err := GenerateJWT("audit", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	for i := 0; i < 2; i++ {
+		_, _, _ = GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+	}
+
+	if records, _ := refreshTokenStore.FindBySubject("audit"); len(records) != 1 {
+		t.Fatalf("the listener issued %d sessions, want 1", len(records))
+	}
+}
+
+func TestSessionLimiterRequiresSubjectIndexedStore(t *testing.T) {
+	limiter := NewSessionLimiter(1, RejectNewSession)
+	_, _, err := GenerateJWT("alice", testSecret, time.Minute, unindexedRefreshTokenStore{}, WithSessionLimit(limiter))
+	if err == nil {
+		t.Fatal("the limit was silently ignored for a store that can't list sessions")
+	}
+
+	// Without a limit there is nothing to look up
+	_, _, err = GenerateJWT("alice", testSecret, time.Minute, unindexedRefreshTokenStore{}, WithSessionLimit(NewSessionLimiter(0, RejectNewSession)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type unindexedRefreshTokenStore struct {
+	Store.RefreshTokenStore
+}
+
+func (unindexedRefreshTokenStore) Save(refreshToken, username string) error {
+	return nil
+}
+
+func TestSessionLimiterConcurrentLogins(t *testing.T) {
+	for _, policy := range []SessionLimitPolicy{RejectNewSession, EvictOldestSession} {
+		t.Run(policy.String(), func(t *testing.T) {
+			const maxSessions, logins = 3, 50
+			refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+			limiter := NewSessionLimiter(maxSessions, policy)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 2*logins)
+			for i := 0; i < logins; i++ {
+				for _, subject := range []string{"alice", "bob"} {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, _, err := GenerateJWT(subject, testSecret, time.Minute, refreshTokenStore, WithSessionLimit(limiter))
+						errs <- err
+					}()
+				}
+			}
+			wg.Wait()
+			close(errs)
+
+			rejected := 0
+			for err := range errs {
+				if errors.Is(err, ErrSessionLimitReached) {
+					rejected++
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			wantRejected := 0
+			if policy == RejectNewSession {
+				wantRejected = 2 * (logins - maxSessions)
+			}
+			if rejected != wantRejected {
+				t.Fatalf("got %d rejected logins, want %d", rejected, wantRejected)
+			}
+
+			for _, subject := range []string{"alice", "bob"} {
+				records, _ := refreshTokenStore.FindBySubject(subject)
+				if len(records) != maxSessions {
+					t.Fatalf("%s has %d sessions, want %d", subject, len(records), maxSessions)
+				}
+			}
+
+			if len(limiter.subjectLocks) != 0 {
+				t.Fatalf("%d subject locks were kept after all logins finished", len(limiter.subjectLocks))
+			}
+		})
+	}
+}
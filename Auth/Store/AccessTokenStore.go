@@ -0,0 +1,86 @@
+package Store
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// AccessTokenRecord holds everything the server knows about an opaque access token.
+type AccessTokenRecord struct {
+	Subject   string
+	ClientID  string
+	Scope     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// AccessTokenStore persists opaque access tokens, which carry no information of their own.
+type AccessTokenStore interface {
+	Save(accessToken string, record AccessTokenRecord) error
+	Find(accessToken string) (*AccessTokenRecord, error)
+	Delete(accessToken string) error
+}
+
+// accessTokenPurgeInterval is how often InMemoryAccessTokenStore drops expired tokens.
+const accessTokenPurgeInterval = time.Minute
+
+// InMemoryAccessTokenStore is a simple in-memory implementation of AccessTokenStore.
+// Expired tokens are dropped on lookup and, at most once per minute, all at once when a token is saved.
+type InMemoryAccessTokenStore struct {
+	mu        sync.RWMutex
+	tokens    map[string]AccessTokenRecord
+	lastPurge time.Time
+}
+
+// NewInMemoryAccessTokenStore creates a new instance of InMemoryAccessTokenStore.
+func NewInMemoryAccessTokenStore() *InMemoryAccessTokenStore {
+	return &InMemoryAccessTokenStore{
+		tokens: make(map[string]AccessTokenRecord),
+	}
+}
+
+// Save stores an access token with its record.
+func (store *InMemoryAccessTokenStore) Save(accessToken string, record AccessTokenRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Drop expired tokens so the store doesn't grow without bound, without a full scan on every save
+	now := time.Now()
+	if now.Sub(store.lastPurge) > accessTokenPurgeInterval {
+		for token, stored := range store.tokens {
+			if now.After(stored.ExpiresAt) {
+				delete(store.tokens, token)
+			}
+		}
+		store.lastPurge = now
+	}
+
+	store.tokens[accessToken] = record
+	return nil
+}
+
+// Find retrieves the record of an access token.
+func (store *InMemoryAccessTokenStore) Find(accessToken string) (*AccessTokenRecord, error) {
+	store.mu.RLock()
+	record, ok := store.tokens[accessToken]
+	store.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New("access token not found")
+	}
+	if time.Now().After(record.ExpiresAt) {
+		_ = store.Delete(accessToken)
+		return nil, errors.New("access token not found")
+	}
+	return &record, nil
+}
+
+// Delete removes an access token from storage.
+func (store *InMemoryAccessTokenStore) Delete(accessToken string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.tokens, accessToken)
+	return nil
+}
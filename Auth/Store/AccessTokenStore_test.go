@@ -0,0 +1,32 @@
+package Store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInMemoryAccessTokenStoreDropsExpiredTokens(t *testing.T) {
+	store := NewInMemoryAccessTokenStore()
+	now := time.Now()
+
+	_ = store.Save("expired-1", AccessTokenRecord{Subject: "alice", ExpiresAt: now.Add(-time.Second)})
+	_ = store.Save("expired-2", AccessTokenRecord{Subject: "alice", ExpiresAt: now.Add(-time.Second)})
+	_ = store.Save("valid", AccessTokenRecord{Subject: "alice", ExpiresAt: now.Add(time.Hour)})
+
+	if _, err := store.Find("expired-1"); err == nil {
+		t.Fatal("Find returned an expired token")
+	}
+	if _, ok := store.tokens["expired-1"]; ok {
+		t.Fatal("Find kept the expired token")
+	}
+
+	// The next save after the purge interval sweeps the rest
+	store.lastPurge = now.Add(-2 * accessTokenPurgeInterval)
+	_ = store.Save("another", AccessTokenRecord{Subject: "alice", ExpiresAt: now.Add(time.Hour)})
+	if len(store.tokens) != 2 {
+		t.Fatalf("got %d tokens after the purge, want 2", len(store.tokens))
+	}
+	if _, err := store.Find("valid"); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+}
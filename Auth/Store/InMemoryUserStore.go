@@ -3,10 +3,18 @@ package Store
 import (
 	"errors"
 	"github.com/ayushs-2k4/go-security/model"
+	"slices"
+	"sync"
+	"time"
 )
 
+// passwordHistoryLimit is how many previous password hashes are kept per user.
+const passwordHistoryLimit = 24
+
 // InMemoryUserStore is a simple in-memory implementation of UserStore.
+// It is safe for concurrent use; users are stored and returned as copies, so use SaveUser to change one.
 type InMemoryUserStore struct {
+	mu            sync.RWMutex
 	users         map[string]*model.User
 	refreshTokens map[string]string // stores refresh tokens by email
 }
@@ -19,22 +27,31 @@ func NewInMemoryUserStore() *InMemoryUserStore {
 }
 
 func (store *InMemoryUserStore) FindUserByUsername(username string) (*model.User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
 	user, exists := store.users[username]
 	if !exists {
-		return nil, errors.New("user not found") // User not found
+		return nil, model.ErrUserNotFound
 	}
-	return user, nil
+	return copyUser(user), nil
 }
 
 func (store *InMemoryUserStore) SaveRefreshToken(email, refreshToken string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
 	if _, exists := store.users[email]; !exists {
-		return errors.New("user not found")
+		return model.ErrUserNotFound
 	}
 	store.refreshTokens[refreshToken] = email
 	return nil
 }
 
 func (store *InMemoryUserStore) ValidateRefreshToken(refreshToken string) (string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
 	email, exists := store.refreshTokens[refreshToken]
 	if !exists {
 		return "", errors.New("invalid refresh token")
@@ -42,11 +59,63 @@ func (store *InMemoryUserStore) ValidateRefreshToken(refreshToken string) (strin
 	return email, nil
 }
 
-func (store *InMemoryUserStore) AddUser(username string, password string) {
-	store.users[username] = &model.User{
-		Username: username,
-		Password: password,
+func (store *InMemoryUserStore) UpdatePassword(username, encodedPassword string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	user, exists := store.users[username]
+	if !exists {
+		return model.ErrUserNotFound
+	}
+	user.Password = encodedPassword
+	return nil
+}
+
+func (store *InMemoryUserStore) ChangePassword(username, encodedPassword string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	user, exists := store.users[username]
+	if !exists {
+		return model.ErrUserNotFound
+	}
+	user.PasswordHistory = append([]string{user.Password}, user.PasswordHistory...)
+	if len(user.PasswordHistory) > passwordHistoryLimit {
+		user.PasswordHistory = user.PasswordHistory[:passwordHistoryLimit]
 	}
+	user.Password = encodedPassword
+	user.PasswordChangedAt = time.Now()
+	user.MustChangePassword = false
+	user.CredentialsExpired = false
+	return nil
+}
+
+func (store *InMemoryUserStore) MarkEmailVerified(username string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	user, exists := store.users[username]
+	if !exists {
+		return model.ErrUserNotFound
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+// SaveUser creates or replaces a user, e.g. to lock an account or assign roles.
+func (store *InMemoryUserStore) SaveUser(user *model.User) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.users[user.Username] = copyUser(user)
+}
+
+func (store *InMemoryUserStore) AddUser(username string, password string) {
+	store.SaveUser(&model.User{
+		Username:          username,
+		Password:          password,
+		PasswordChangedAt: time.Now(),
+	})
 }
 
 func (store *InMemoryUserStore) AddUsers(users map[string]string) {
@@ -54,3 +123,10 @@ func (store *InMemoryUserStore) AddUsers(users map[string]string) {
 		store.AddUser(username, password)
 	}
 }
+
+func copyUser(user *model.User) *model.User {
+	userCopy := *user
+	userCopy.PasswordHistory = slices.Clone(user.PasswordHistory)
+	userCopy.Roles = slices.Clone(user.Roles)
+	return &userCopy
+}
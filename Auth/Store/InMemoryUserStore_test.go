@@ -0,0 +1,92 @@
+package Store
+
+import (
+	"fmt"
+	"github.com/ayushs-2k4/go-security/model"
+	"sync"
+	"testing"
+)
+
+func TestInMemoryUserStoreConcurrentAccess(t *testing.T) {
+	store := NewInMemoryUserStore()
+	store.AddUser("alice", "{noop}password 0")
+
+	const workers = 8
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				err := store.ChangePassword("alice", fmt.Sprintf("{noop}password %d-%d", worker, i))
+				if err != nil {
+					t.Errorf("ChangePassword: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				user, err := store.FindUserByUsername("alice")
+				if err != nil {
+					t.Errorf("FindUserByUsername: %v", err)
+					return
+				}
+				// Returned users are copies, changing them must not race with the store
+				user.Roles = append(user.Roles, "reader")
+				user.PasswordHistory = append(user.PasswordHistory, user.Password)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				store.SaveUser(&model.User{Username: fmt.Sprintf("user-%d-%d", worker, i), Roles: []string{"reader"}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	user, err := store.FindUserByUsername("alice")
+	if err != nil {
+		t.Fatalf("FindUserByUsername: %v", err)
+	}
+	if len(user.PasswordHistory) != passwordHistoryLimit {
+		t.Fatalf("got %d previous passwords, want %d", len(user.PasswordHistory), passwordHistoryLimit)
+	}
+	if len(user.Roles) != 0 {
+		t.Fatalf("got roles %v, changes to a returned user leaked into the store", user.Roles)
+	}
+}
+
+func BenchmarkInMemoryUserStoreFindUserByUsername(b *testing.B) {
+	store := NewInMemoryUserStore()
+	store.AddUser("alice", "{noop}password")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := store.FindUserByUsername("alice")
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryUserStoreChangePassword(b *testing.B) {
+	store := NewInMemoryUserStore()
+	store.AddUser("alice", "{noop}password")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			err := store.ChangePassword("alice", "{noop}new password")
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package Store
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginAttempts is the failed login history of a username or client IP.
+type LoginAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// LoginAttemptStore tracks failed logins. Implementations backed by a shared store let all replicas
+// of a service enforce the same limits.
+type LoginAttemptStore interface {
+	// Find returns the attempts recorded for key, or a zero value if there are none.
+	Find(key string) (LoginAttempts, error)
+
+	// RecordFailure atomically increments the failure count of key and returns the updated attempts.
+	RecordFailure(key string, at time.Time) (LoginAttempts, error)
+
+	// RemoveFailure atomically takes back one failure recorded by RecordFailure, e.g. for an attempt that was
+	// counted before it turned out to succeed.
+	RemoveFailure(key string) error
+
+	// Lock rejects all logins for key until the given time.
+	Lock(key string, until time.Time) error
+
+	// Reset forgets all attempts of key.
+	Reset(key string) error
+}
+
+// InMemoryLoginAttemptStore is a simple in-memory implementation of LoginAttemptStore.
+type InMemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempts
+}
+
+// NewInMemoryLoginAttemptStore creates a new instance of InMemoryLoginAttemptStore.
+func NewInMemoryLoginAttemptStore() *InMemoryLoginAttemptStore {
+	return &InMemoryLoginAttemptStore{
+		attempts: make(map[string]LoginAttempts),
+	}
+}
+
+// Find returns the attempts recorded for key.
+func (store *InMemoryLoginAttemptStore) Find(key string) (LoginAttempts, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.attempts[key], nil
+}
+
+// RecordFailure increments the failure count of key.
+func (store *InMemoryLoginAttemptStore) RecordFailure(key string, at time.Time) (LoginAttempts, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts := store.attempts[key]
+	attempts.Failures++
+	attempts.LastFailure = at
+	store.attempts[key] = attempts
+
+	return attempts, nil
+}
+
+// RemoveFailure decrements the failure count of key.
+func (store *InMemoryLoginAttemptStore) RemoveFailure(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts, ok := store.attempts[key]
+	if !ok || attempts.Failures == 0 {
+		return nil
+	}
+
+	attempts.Failures--
+	store.attempts[key] = attempts
+	return nil
+}
+
+// Lock rejects all logins for key until the given time.
+func (store *InMemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	attempts := store.attempts[key]
+	attempts.LockedUntil = until
+	store.attempts[key] = attempts
+
+	return nil
+}
+
+// Reset forgets all attempts of key.
+func (store *InMemoryLoginAttemptStore) Reset(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.attempts, key)
+	return nil
+}
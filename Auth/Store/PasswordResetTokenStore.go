@@ -0,0 +1,96 @@
+package Store
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// PasswordResetToken is a pending password reset. Stores only ever see a hash of the token itself.
+type PasswordResetToken struct {
+	Subject   string
+	ExpiresAt time.Time
+}
+
+// PasswordResetTokenStore persists pending password resets keyed by token hash.
+type PasswordResetTokenStore interface {
+	Save(tokenHash string, token PasswordResetToken) error
+
+	// Find retrieves a token without consuming it.
+	Find(tokenHash string) (*PasswordResetToken, error)
+
+	// Consume atomically retrieves and deletes a token, so it can be used only once.
+	Consume(tokenHash string) (*PasswordResetToken, error)
+
+	// DeleteBySubject removes every pending token of a subject.
+	DeleteBySubject(subject string) error
+}
+
+// InMemoryPasswordResetTokenStore is a simple in-memory implementation of PasswordResetTokenStore.
+type InMemoryPasswordResetTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]PasswordResetToken
+}
+
+// NewInMemoryPasswordResetTokenStore creates a new instance of InMemoryPasswordResetTokenStore.
+func NewInMemoryPasswordResetTokenStore() *InMemoryPasswordResetTokenStore {
+	return &InMemoryPasswordResetTokenStore{
+		tokens: make(map[string]PasswordResetToken),
+	}
+}
+
+// Save stores a reset token under its hash.
+func (store *InMemoryPasswordResetTokenStore) Save(tokenHash string, token PasswordResetToken) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Drop expired tokens so the store doesn't grow without bound
+	now := time.Now()
+	for hash, pending := range store.tokens {
+		if now.After(pending.ExpiresAt) {
+			delete(store.tokens, hash)
+		}
+	}
+
+	store.tokens[tokenHash] = token
+	return nil
+}
+
+// Find retrieves a reset token.
+func (store *InMemoryPasswordResetTokenStore) Find(tokenHash string) (*PasswordResetToken, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	token, ok := store.tokens[tokenHash]
+	if !ok {
+		return nil, errors.New("password reset token not found")
+	}
+	return &token, nil
+}
+
+// Consume retrieves and deletes a reset token.
+func (store *InMemoryPasswordResetTokenStore) Consume(tokenHash string) (*PasswordResetToken, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	token, ok := store.tokens[tokenHash]
+	if !ok {
+		return nil, errors.New("password reset token not found")
+	}
+
+	delete(store.tokens, tokenHash)
+	return &token, nil
+}
+
+// DeleteBySubject removes every reset token of a subject.
+func (store *InMemoryPasswordResetTokenStore) DeleteBySubject(subject string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for hash, pending := range store.tokens {
+		if pending.Subject == subject {
+			delete(store.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package Store
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// RedisRefreshTokenStore is a RefreshTokenStore backed by Redis, so refresh tokens work across replicas.
+// Tokens expire after the configured TTL. Each token is a hash, and a sorted set per subject indexes them by
+// issue time. Keys and index entries hold a SHA-256 hash of the token rather than the token itself; records
+// returned by FindBySubject carry it as Token, and Delete accepts it in place of the token.
+//
+// On Redis Cluster, use a key prefix with a hash tag, e.g. "{auth}:", so that Rotate and Delete, which touch
+// several keys at once, stay within one slot.
+type RedisRefreshTokenStore struct {
+	client    redis.UniversalClient
+	ttl       time.Duration
+	keyPrefix string
+}
+
+// NewRedisRefreshTokenStore creates a RedisRefreshTokenStore whose tokens expire after ttl; a ttl of zero or
+// less lets them live until they are deleted.
+func NewRedisRefreshTokenStore(client redis.UniversalClient, ttl time.Duration) *RedisRefreshTokenStore {
+	return &RedisRefreshTokenStore{
+		client:    client,
+		ttl:       ttl,
+		keyPrefix: "auth:",
+	}
+}
+
+// WithKeyPrefix sets the prefix of every key, "auth:" by default.
+func (store *RedisRefreshTokenStore) WithKeyPrefix(keyPrefix string) *RedisRefreshTokenStore {
+	store.keyPrefix = keyPrefix
+	return store
+}
+
+func (store *RedisRefreshTokenStore) tokenKey(handle string) string {
+	return store.keyPrefix + "refresh_token:" + handle
+}
+
+func (store *RedisRefreshTokenStore) subjectKey(subject string) string {
+	return store.keyPrefix + "refresh_tokens_by_subject:" + subject
+}
+
+// saveScript stores a token and indexes it under its subject, dropping index entries that have expired.
+// A TTL of zero or less skips the expiry, as PEXPIRE would delete the keys right away.
+//
+// KEYS: token key, subject index key
+// ARGV: token hash, subject, client ID, issued at (ms), binding, TTL (ms)
+var saveScript = redis.NewScript(`
+local ttl = tonumber(ARGV[6])
+redis.call('HSET', KEYS[1], 'subject', ARGV[2], 'client_id', ARGV[3], 'issued_at', ARGV[4], 'binding', ARGV[5])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+	redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', '(' .. (tonumber(ARGV[4]) - ttl))
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return 1
+`)
+
+// rotateScript deletes the old token and saves the new one, failing if the old token doesn't exist.
+//
+// KEYS: old token key, new token key, subject index key
+// ARGV: old token hash, new token hash, subject, client ID, issued at (ms), binding, TTL (ms)
+var rotateScript = redis.NewScript(`
+local ttl = tonumber(ARGV[7])
+if redis.call('DEL', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[3], ARGV[1])
+redis.call('HSET', KEYS[2], 'subject', ARGV[3], 'client_id', ARGV[4], 'issued_at', ARGV[5], 'binding', ARGV[6])
+redis.call('ZADD', KEYS[3], ARGV[5], ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+	redis.call('PEXPIRE', KEYS[3], ttl)
+end
+return 1
+`)
+
+// deleteScript deletes a token and its index entry. The subject index key is only known once the token was
+// read, so the script builds it from the key prefix of the store.
+//
+// KEYS: token key
+// ARGV: token hash, subject index key prefix
+var deleteScript = redis.NewScript(`
+local subject = redis.call('HGET', KEYS[1], 'subject')
+if not subject then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', ARGV[2] .. subject, ARGV[1])
+return 1
+`)
+
+// Save saves a refresh token and associated subject.
+func (store *RedisRefreshTokenStore) Save(refreshToken, subject string) error {
+	return store.SaveRecord(RefreshTokenRecord{
+		Token:    refreshToken,
+		Subject:  subject,
+		IssuedAt: time.Now(),
+	})
+}
+
+// SaveRecord saves a refresh token with its client and binding.
+func (store *RedisRefreshTokenStore) SaveRecord(record RefreshTokenRecord) error {
+	ctx := context.Background()
+
+	handle := hashRefreshToken(record.Token)
+	keys := []string{store.tokenKey(handle), store.subjectKey(record.Subject)}
+	return saveScript.Run(ctx, store.client, keys,
+		handle, record.Subject, record.ClientID, record.IssuedAt.UnixMilli(), record.Binding, store.ttl.Milliseconds()).Err()
+}
+
+// Rotate replaces a refresh token by a new one in a single atomic script.
+func (store *RedisRefreshTokenStore) Rotate(oldRefreshToken string, record RefreshTokenRecord) error {
+	ctx := context.Background()
+
+	oldHandle, newHandle := hashRefreshToken(oldRefreshToken), hashRefreshToken(record.Token)
+	keys := []string{store.tokenKey(oldHandle), store.tokenKey(newHandle), store.subjectKey(record.Subject)}
+	rotated, err := rotateScript.Run(ctx, store.client, keys,
+		oldHandle, newHandle, record.Subject, record.ClientID, record.IssuedAt.UnixMilli(), record.Binding,
+		store.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if rotated == 0 {
+		return errors.New("refresh token not found")
+	}
+	return nil
+}
+
+// FindRecord retrieves the record of a refresh token.
+func (store *RedisRefreshTokenStore) FindRecord(refreshToken string) (*RefreshTokenRecord, error) {
+	fields, err := store.client.HGetAll(context.Background(), store.tokenKey(hashRefreshToken(refreshToken))).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("refresh token not found")
+	}
+
+	record := recordFromFields(refreshToken, fields)
+	return &record, nil
+}
+
+// FindSubject retrieves the subject associated with a refresh token.
+func (store *RedisRefreshTokenStore) FindSubject(refreshToken string) (string, error) {
+	record, err := store.FindRecord(refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return record.Subject, nil
+}
+
+func recordFromFields(refreshToken string, fields map[string]string) RefreshTokenRecord {
+	issuedAt, _ := strconv.ParseInt(fields["issued_at"], 10, 64)
+	return RefreshTokenRecord{
+		Token:    refreshToken,
+		Subject:  fields["subject"],
+		ClientID: fields["client_id"],
+		IssuedAt: time.UnixMilli(issuedAt),
+		Binding:  fields["binding"],
+	}
+}
+
+// FindBySubject retrieves all refresh tokens issued to a subject, oldest first.
+func (store *RedisRefreshTokenStore) FindBySubject(subject string) ([]RefreshTokenRecord, error) {
+	ctx := context.Background()
+
+	handles, err := store.client.ZRange(ctx, store.subjectKey(subject), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := store.client.Pipeline()
+	commands := make([]*redis.MapStringStringCmd, len(handles))
+	for i, handle := range handles {
+		commands[i] = pipe.HGetAll(ctx, store.tokenKey(handle))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	records := make([]RefreshTokenRecord, 0, len(handles))
+	for i, handle := range handles {
+		fields := commands[i].Val()
+		if len(fields) == 0 {
+			continue // Expired, the index entry is dropped on the next save
+		}
+
+		records = append(records, recordFromFields(handle, fields))
+	}
+
+	return records, nil
+}
+
+// Delete removes a refresh token, given as the token or its hash, from storage in a single atomic script.
+func (store *RedisRefreshTokenStore) Delete(refreshToken string) error {
+	ctx := context.Background()
+
+	handle := refreshTokenHandle(refreshToken)
+	return deleteScript.Run(ctx, store.client, []string{store.tokenKey(handle)}, handle, store.subjectKey("")).Err()
+}
+
+// ************************************************************************ //
+
+// RedisTokenDenylist is a TokenDenylist backed by Redis. Entries expire with the tokens they revoke.
+type RedisTokenDenylist struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+// NewRedisTokenDenylist creates a new instance of RedisTokenDenylist.
+func NewRedisTokenDenylist(client redis.UniversalClient) *RedisTokenDenylist {
+	return &RedisTokenDenylist{
+		client:    client,
+		keyPrefix: "auth:",
+	}
+}
+
+// WithKeyPrefix sets the prefix of every key, "auth:" by default.
+func (store *RedisTokenDenylist) WithKeyPrefix(keyPrefix string) *RedisTokenDenylist {
+	store.keyPrefix = keyPrefix
+	return store
+}
+
+// Revoke adds a JWT ID to the denylist until expiresAt.
+func (store *RedisTokenDenylist) Revoke(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil // Expired tokens are rejected anyway
+	}
+
+	return store.client.Set(context.Background(), store.keyPrefix+"revoked_jti:"+jti, "1", ttl).Err()
+}
+
+// IsRevoked reports whether a JWT ID has been revoked.
+func (store *RedisTokenDenylist) IsRevoked(jti string) (bool, error) {
+	n, err := store.client.Exists(context.Background(), store.keyPrefix+"revoked_jti:"+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,253 @@
+package Store
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return server, client
+}
+
+func TestRedisRefreshTokenStoreRoundTrip(t *testing.T) {
+	server, client := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, time.Hour)
+
+	// Issued a while ago, so it sorts before tokens saved within the same millisecond
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	err := store.SaveRecord(RefreshTokenRecord{Token: "token-1", Subject: "alice", ClientID: "app", IssuedAt: issuedAt, Binding: "jkt"})
+	if err != nil {
+		t.Fatalf("SaveRecord: %v", err)
+	}
+
+	record, err := store.FindRecord("token-1")
+	if err != nil {
+		t.Fatalf("FindRecord: %v", err)
+	}
+	want := RefreshTokenRecord{Token: "token-1", Subject: "alice", ClientID: "app", IssuedAt: issuedAt, Binding: "jkt"}
+	if *record != want {
+		t.Fatalf("got %+v, want %+v", *record, want)
+	}
+
+	for _, key := range server.Keys() {
+		if strings.Contains(key, "token-1") {
+			t.Fatalf("key %q holds the plaintext token", key)
+		}
+	}
+	members, err := server.ZMembers("auth:refresh_tokens_by_subject:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || !strings.HasPrefix(members[0], hashedRefreshTokenPrefix) {
+		t.Fatalf("got subject index %v, want the token hash", members)
+	}
+	_, err = store.FindRecord(members[0])
+	if err == nil {
+		t.Fatal("FindRecord accepted the stored hash in place of the token")
+	}
+
+	err = store.Save("token-2", "alice")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	records, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatalf("FindBySubject: %v", err)
+	}
+	if len(records) != 2 || records[0].Token != members[0] {
+		t.Fatalf("got %+v, want both tokens, oldest first", records)
+	}
+
+	// Records of FindBySubject carry the hash, which must be enough to revoke them
+	for _, record := range records {
+		err = store.Delete(record.Token)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v after deleting every token", keys)
+	}
+}
+
+func TestRedisRefreshTokenStoreExpiry(t *testing.T) {
+	server, client := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, time.Minute)
+
+	err := store.Save("token-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.FastForward(2 * time.Minute)
+
+	_, err = store.FindSubject("token-1")
+	if err == nil {
+		t.Fatal("token is still valid after its TTL")
+	}
+}
+
+func TestRedisRefreshTokenStoreWithoutTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		server, client := newTestRedis(t)
+		store := NewRedisRefreshTokenStore(client, ttl)
+
+		err := store.Save("token-1", "alice")
+		if err != nil {
+			t.Fatalf("ttl %v: Save: %v", ttl, err)
+		}
+		err = store.Rotate("token-1", RefreshTokenRecord{Token: "token-2", Subject: "alice", IssuedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("ttl %v: Rotate: %v", ttl, err)
+		}
+
+		server.FastForward(24 * time.Hour)
+
+		subject, err := store.FindSubject("token-2")
+		if err != nil || subject != "alice" {
+			t.Fatalf("ttl %v: got %q, %v, want the token to be kept", ttl, subject, err)
+		}
+		records, err := store.FindBySubject("alice")
+		if err != nil || len(records) != 1 {
+			t.Fatalf("ttl %v: got %d records, %v, want 1", ttl, len(records), err)
+		}
+	}
+}
+
+func TestRedisRefreshTokenStoreRotate(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, time.Hour)
+	err := store.Save("token-0", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Rotate("unknown", RefreshTokenRecord{Token: "token-x", Subject: "alice"})
+	if err == nil {
+		t.Fatal("Rotate of an unknown token succeeded")
+	}
+	if _, err := store.FindSubject("token-x"); err == nil {
+		t.Fatal("failed Rotate saved the new token")
+	}
+
+	// Only one of several concurrent rotations of the same token may succeed
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for _, newToken := range []string{"token-a", "token-b", "token-c", "token-d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Rotate("token-0", RefreshTokenRecord{Token: newToken, Subject: "alice", IssuedAt: time.Now()}) == nil {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wins.Load() != 1 {
+		t.Fatalf("%d rotations succeeded, want 1", wins.Load())
+	}
+	if _, err := store.FindSubject("token-0"); err == nil {
+		t.Fatal("rotated token is still valid")
+	}
+	records, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d tokens, want 1", len(records))
+	}
+}
+
+func TestRedisRefreshTokenStoreDelete(t *testing.T) {
+	server, client := newTestRedis(t)
+	store := NewRedisRefreshTokenStore(client, time.Hour).WithKeyPrefix("{auth}:")
+
+	err := store.Delete("unknown")
+	if err != nil {
+		t.Fatalf("Delete of an unknown token: %v", err)
+	}
+
+	err = store.Save("token-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete("token-1")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v after deleting the only token", keys)
+	}
+
+	// Deleting a token while it is rotated leaves either nothing or only the new token, never a stray index
+	// entry or an unindexed token
+	for i := 0; i < 20; i++ {
+		err := store.Save("token-0", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = store.Rotate("token-0", RefreshTokenRecord{Token: "token-new", Subject: "alice", IssuedAt: time.Now()})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = store.Delete("token-0")
+		}()
+		wg.Wait()
+
+		members, _ := server.ZMembers("{auth}:refresh_tokens_by_subject:alice")
+		tokenKeys := 0
+		for _, key := range server.Keys() {
+			if strings.HasPrefix(key, "{auth}:refresh_token:") {
+				tokenKeys++
+			}
+		}
+		if len(members) != tokenKeys || len(members) > 1 {
+			t.Fatalf("got index %v and %d token keys, want them to match", members, tokenKeys)
+		}
+
+		_ = store.Delete("token-new")
+	}
+}
+
+func TestRedisTokenDenylist(t *testing.T) {
+	server, client := newTestRedis(t)
+	denylist := NewRedisTokenDenylist(client)
+
+	err := denylist.Revoke("jti-1", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	err = denylist.Revoke("jti-2", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Revoke of an expired token: %v", err)
+	}
+
+	if revoked, err := denylist.IsRevoked("jti-1"); err != nil || !revoked {
+		t.Fatalf("jti-1: got %v, %v, want revoked", revoked, err)
+	}
+	if revoked, err := denylist.IsRevoked("jti-2"); err != nil || revoked {
+		t.Fatalf("jti-2: got %v, %v, want not revoked", revoked, err)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if revoked, err := denylist.IsRevoked("jti-1"); err != nil || revoked {
+		t.Fatalf("jti-1 after expiry: got %v, %v, want the entry to be gone", revoked, err)
+	}
+}
@@ -1,6 +1,14 @@
 package Store
 
-import "errors"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
 
 type RefreshTokenStore interface {
 	Save(refreshToken, subject string) error
@@ -8,35 +16,194 @@ type RefreshTokenStore interface {
 	Delete(refreshToken string) error
 }
 
-// InMemoryRefreshTokenStore is a simple in-memory implementation of RefreshTokenStore.
+// RefreshTokenRecord describes a refresh token together with the subject it was issued to.
+type RefreshTokenRecord struct {
+	Token    string // stores that keep only a hash of the token return a handle here, which Delete accepts
+	Subject  string
+	ClientID string // OAuth client the token was issued to, empty if unknown
+	IssuedAt time.Time
+	Binding  string // thumbprint of the key or client certificate the token is bound to, empty for bearer tokens
+}
+
+// SubjectIndexedRefreshTokenStore is a RefreshTokenStore that can list the refresh tokens issued to a subject.
+type SubjectIndexedRefreshTokenStore interface {
+	RefreshTokenStore
+
+	// FindBySubject returns the refresh tokens of a subject, oldest first.
+	FindBySubject(subject string) ([]RefreshTokenRecord, error)
+}
+
+// RecordRefreshTokenStore is a RefreshTokenStore that keeps the whole RefreshTokenRecord, so refresh tokens can
+// be tied to the client they were issued to and bound to a proof-of-possession key.
+type RecordRefreshTokenStore interface {
+	RefreshTokenStore
+
+	SaveRecord(record RefreshTokenRecord) error
+	FindRecord(refreshToken string) (*RefreshTokenRecord, error)
+}
+
+// RotatingRefreshTokenStore is a RefreshTokenStore that exchanges a refresh token for a new one in a single
+// atomic step, so that concurrent refreshes with the same token can't both succeed.
+type RotatingRefreshTokenStore interface {
+	RefreshTokenStore
+
+	// Rotate deletes oldRefreshToken and saves record, failing without changes if oldRefreshToken doesn't exist.
+	Rotate(oldRefreshToken string, record RefreshTokenRecord) error
+}
+
+// hashedRefreshTokenPrefix marks the handles returned in place of tokens by stores that keep only token hashes.
+const hashedRefreshTokenPrefix = "sha256:"
+
+// hashRefreshToken returns the handle a store keeps in place of refreshToken, so that leaked storage doesn't
+// hand out usable tokens.
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hashedRefreshTokenPrefix + hex.EncodeToString(sum[:])
+}
+
+// refreshTokenHandle returns the handle of refreshToken, which may already be one, e.g. taken from a record
+// returned by FindBySubject. Only deletions may accept handles, or a leaked hash would work as the token.
+func refreshTokenHandle(refreshToken string) string {
+	if strings.HasPrefix(refreshToken, hashedRefreshTokenPrefix) {
+		return refreshToken
+	}
+	return hashRefreshToken(refreshToken)
+}
+
+// refreshTokenShards is the number of independently locked parts of InMemoryRefreshTokenStore.
+const refreshTokenShards = 32
+
+// InMemoryRefreshTokenStore is a simple in-memory implementation of RefreshTokenStore. Tokens are spread over
+// shards with a lock each, so concurrent logins and refreshes of different tokens rarely wait for each other.
 type InMemoryRefreshTokenStore struct {
-	tokens map[string]string
+	shards [refreshTokenShards]refreshTokenShard
+}
+
+type refreshTokenShard struct {
+	mu     sync.RWMutex
+	tokens map[string]RefreshTokenRecord
 }
 
 // NewInMemoryRefreshTokenStore creates a new instance of InMemoryRefreshTokenStore.
 func NewInMemoryRefreshTokenStore() *InMemoryRefreshTokenStore {
-	return &InMemoryRefreshTokenStore{
-		tokens: make(map[string]string),
+	store := &InMemoryRefreshTokenStore{}
+	for i := range store.shards {
+		store.shards[i].tokens = make(map[string]RefreshTokenRecord)
+	}
+	return store
+}
+
+// shardIndex picks the shard of a token with FNV-1a.
+func shardIndex(refreshToken string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(refreshToken); i++ {
+		hash ^= uint32(refreshToken[i])
+		hash *= 16777619
 	}
+	return int(hash % refreshTokenShards)
+}
+
+func (store *InMemoryRefreshTokenStore) shard(refreshToken string) *refreshTokenShard {
+	return &store.shards[shardIndex(refreshToken)]
 }
 
 // Save saves a refresh token and associated username.
 func (store *InMemoryRefreshTokenStore) Save(refreshToken, username string) error {
-	store.tokens[refreshToken] = username
+	return store.SaveRecord(RefreshTokenRecord{
+		Token:    refreshToken,
+		Subject:  username,
+		IssuedAt: time.Now(),
+	})
+}
+
+// SaveRecord saves a refresh token with its client and binding.
+func (store *InMemoryRefreshTokenStore) SaveRecord(record RefreshTokenRecord) error {
+	shard := store.shard(record.Token)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.tokens[record.Token] = record
 	return nil
 }
 
+// FindRecord retrieves the record of a refresh token.
+func (store *InMemoryRefreshTokenStore) FindRecord(refreshToken string) (*RefreshTokenRecord, error) {
+	shard := store.shard(refreshToken)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	record, ok := shard.tokens[refreshToken]
+	if !ok {
+		return nil, errors.New("refresh token not found")
+	}
+	return &record, nil
+}
+
 // FindSubject retrieves the username associated with a refresh token.
 func (store *InMemoryRefreshTokenStore) FindSubject(refreshToken string) (string, error) {
-	username, ok := store.tokens[refreshToken]
-	if !ok {
-		return "", errors.New("refresh token not found")
+	record, err := store.FindRecord(refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return record.Subject, nil
+}
+
+// FindBySubject retrieves all refresh tokens issued to a subject, oldest first. It holds every shard at once,
+// so a concurrent Rotate shows up as either the old or the new token, never both or neither.
+func (store *InMemoryRefreshTokenStore) FindBySubject(subject string) ([]RefreshTokenRecord, error) {
+	for i := range store.shards {
+		store.shards[i].mu.RLock()
 	}
-	return username, nil
+	defer func() {
+		for i := range store.shards {
+			store.shards[i].mu.RUnlock()
+		}
+	}()
+
+	records := make([]RefreshTokenRecord, 0)
+	for i := range store.shards {
+		for _, record := range store.shards[i].tokens {
+			if record.Subject == subject {
+				records = append(records, record)
+			}
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].IssuedAt.Before(records[j].IssuedAt)
+	})
+
+	return records, nil
+}
+
+// Rotate replaces a refresh token by a new one.
+func (store *InMemoryRefreshTokenStore) Rotate(oldRefreshToken string, record RefreshTokenRecord) error {
+	// Lock both shards in index order, so concurrent rotations can't deadlock
+	oldIndex, newIndex := shardIndex(oldRefreshToken), shardIndex(record.Token)
+	first, second := min(oldIndex, newIndex), max(oldIndex, newIndex)
+	store.shards[first].mu.Lock()
+	defer store.shards[first].mu.Unlock()
+	if second != first {
+		store.shards[second].mu.Lock()
+		defer store.shards[second].mu.Unlock()
+	}
+
+	oldShard, newShard := &store.shards[oldIndex], &store.shards[newIndex]
+	if _, ok := oldShard.tokens[oldRefreshToken]; !ok {
+		return errors.New("refresh token not found")
+	}
+
+	delete(oldShard.tokens, oldRefreshToken)
+	newShard.tokens[record.Token] = record
+	return nil
 }
 
 // Delete removes a refresh token from storage.
 func (store *InMemoryRefreshTokenStore) Delete(refreshToken string) error {
-	delete(store.tokens, refreshToken)
+	shard := store.shard(refreshToken)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	delete(shard.tokens, refreshToken)
 	return nil
 }
@@ -0,0 +1,200 @@
+package Store
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInMemoryRefreshTokenStoreConcurrentRotate(t *testing.T) {
+	store := NewInMemoryRefreshTokenStore()
+	err := store.SaveRecord(RefreshTokenRecord{Token: "token-0", Subject: "alice", IssuedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every worker tries to rotate the same token; exactly one may win each round
+	const workers = 8
+	const rounds = 100
+	for round := 0; round < rounds; round++ {
+		oldToken := fmt.Sprintf("token-%d", round)
+
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for worker := 0; worker < workers; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				record := RefreshTokenRecord{
+					Token:    fmt.Sprintf("token-%d-%d", round+1, worker),
+					Subject:  "alice",
+					IssuedAt: time.Now(),
+				}
+				if store.Rotate(oldToken, record) == nil {
+					wins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if wins.Load() != 1 {
+			t.Fatalf("round %d: %d rotations succeeded, want 1", round, wins.Load())
+		}
+
+		records, err := store.FindBySubject("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 {
+			t.Fatalf("round %d: got %d refresh tokens, want 1", round, len(records))
+		}
+		err = store.Rotate(records[0].Token, RefreshTokenRecord{Token: fmt.Sprintf("token-%d", round+1), Subject: "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInMemoryRefreshTokenStoreConcurrentAccess(t *testing.T) {
+	store := NewInMemoryRefreshTokenStore()
+
+	const workers = 8
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				token := fmt.Sprintf("token-%d-%d", worker, i)
+				err := store.SaveRecord(RefreshTokenRecord{Token: token, Subject: "alice", IssuedAt: time.Now()})
+				if err != nil {
+					t.Errorf("SaveRecord: %v", err)
+					return
+				}
+				err = store.Rotate(token, RefreshTokenRecord{Token: token + "-rotated", Subject: "alice", IssuedAt: time.Now()})
+				if err != nil {
+					t.Errorf("Rotate: %v", err)
+					return
+				}
+				err = store.Delete(token + "-rotated")
+				if err != nil {
+					t.Errorf("Delete: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				_, err := store.FindBySubject("alice")
+				if err != nil {
+					t.Errorf("FindBySubject: %v", err)
+					return
+				}
+				_, _ = store.FindRecord(fmt.Sprintf("token-%d-%d", worker, i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("got %d refresh tokens, want all of them deleted", len(records))
+	}
+}
+
+func TestInMemoryRefreshTokenStoreFindBySubjectDuringRotate(t *testing.T) {
+	store := NewInMemoryRefreshTokenStore()
+
+	// Tokens of one subject spread over many shards, each rotated over and over
+	const sessions = 16
+	for i := 0; i < sessions; i++ {
+		_ = store.SaveRecord(RefreshTokenRecord{Token: fmt.Sprintf("token-%d-0", i), Subject: "alice", IssuedAt: time.Now()})
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for generation := 0; ; generation++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				oldToken, newToken := fmt.Sprintf("token-%d-%d", i, generation), fmt.Sprintf("token-%d-%d", i, generation+1)
+				err := store.Rotate(oldToken, RefreshTokenRecord{Token: newToken, Subject: "alice", IssuedAt: time.Now()})
+				if err != nil {
+					t.Errorf("Rotate: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		records, err := store.FindBySubject("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != sessions {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("got %d refresh tokens during rotations, want %d", len(records), sessions)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func BenchmarkInMemoryRefreshTokenStoreRotate(b *testing.B) {
+	store := NewInMemoryRefreshTokenStore()
+
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		token := fmt.Sprintf("token-%d", next.Add(1))
+		err := store.SaveRecord(RefreshTokenRecord{Token: token, Subject: "alice"})
+		if err != nil {
+			b.Error(err)
+			return
+		}
+
+		for pb.Next() {
+			newToken := fmt.Sprintf("token-%d", next.Add(1))
+			err := store.Rotate(token, RefreshTokenRecord{Token: newToken, Subject: "alice"})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			token = newToken
+		}
+	})
+}
+
+func BenchmarkInMemoryRefreshTokenStoreFindRecord(b *testing.B) {
+	store := NewInMemoryRefreshTokenStore()
+	for i := 0; i < 1000; i++ {
+		_ = store.SaveRecord(RefreshTokenRecord{Token: fmt.Sprintf("token-%d", i), Subject: "alice"})
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, err := store.FindRecord(fmt.Sprintf("token-%d", i%1000))
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			i++
+		}
+	})
+}
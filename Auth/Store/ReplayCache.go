@@ -0,0 +1,46 @@
+package Store
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers one-time identifiers, such as DPoP proof jti values, until they expire.
+type ReplayCache interface {
+	// MarkUsed records id and reports whether it was unused before.
+	MarkUsed(id string, expiresAt time.Time) (bool, error)
+}
+
+// InMemoryReplayCache is a simple in-memory implementation of ReplayCache.
+type InMemoryReplayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// NewInMemoryReplayCache creates a new instance of InMemoryReplayCache.
+func NewInMemoryReplayCache() *InMemoryReplayCache {
+	return &InMemoryReplayCache{
+		ids: make(map[string]time.Time),
+	}
+}
+
+// MarkUsed records id and reports whether it was unused before.
+func (cache *InMemoryReplayCache) MarkUsed(id string, expiresAt time.Time) (bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if expiry, ok := cache.ids[id]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	// Drop expired identifiers so the cache doesn't grow without bound
+	for usedID, expiry := range cache.ids {
+		if now.After(expiry) {
+			delete(cache.ids, usedID)
+		}
+	}
+
+	cache.ids[id] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,134 @@
+package Store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect adapts the queries of the database/sql stores to a database.
+type SQLDialect int
+
+const (
+	// SQLiteDialect uses ? placeholders.
+	SQLiteDialect SQLDialect = iota
+	// PostgresDialect uses $1, $2, ... placeholders.
+	PostgresDialect
+)
+
+// rebind rewrites the ? placeholders of query for the dialect.
+func (d SQLDialect) rebind(query string) string {
+	if d != PostgresDialect {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrating, "go-secur" in ASCII.
+const migrationLockID int64 = 0x676f2d7365637572
+
+// MigrateSQLSchema creates or updates the tables used by SQLUserStore and SQLRefreshTokenStore.
+// Every migration runs once in its own transaction and is recorded in the schema_migrations table.
+//
+// On Postgres an advisory lock serializes replicas migrating at the same time. SQLite has no such lock, so
+// there migrate from a single process, before the others start.
+func MigrateSQLSchema(db *sql.DB, dialect SQLDialect) error {
+	ctx := context.Background()
+
+	// Advisory locks belong to a connection, so the whole migration runs on one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if dialect == PostgresDialect {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+	}
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version VARCHAR(255) PRIMARY KEY)")
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = applyMigration(ctx, conn, dialect, name)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect SQLDialect, name string) error {
+	version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+	script, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	err = tx.QueryRow(dialect.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	// Not every driver executes several statements at once
+	for _, statement := range strings.Split(string(script), ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+
+		_, err = tx.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(dialect.rebind("INSERT INTO schema_migrations (version) VALUES (?)"), version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package Store
+
+import (
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = MigrateSQLSchema(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("MigrateSQLSchema: %v", err)
+	}
+
+	return db
+}
+
+func TestMigrateSQLSchemaRunsEveryMigrationOnce(t *testing.T) {
+	db := newTestDB(t)
+
+	// A second run, e.g. by the next deployment, must skip everything already applied
+	err := MigrateSQLSchema(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("MigrateSQLSchema again: %v", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied int
+	err = db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(names) {
+		t.Fatalf("got %d applied migrations, want %d", applied, len(names))
+	}
+}
+
+func TestSQLDialectRebind(t *testing.T) {
+	const query = "SELECT a FROM t WHERE b = ? AND c = ?"
+
+	if got := SQLiteDialect.rebind(query); got != query {
+		t.Fatalf("SQLite: got %q, want the query unchanged", got)
+	}
+	if got, want := PostgresDialect.rebind(query), "SELECT a FROM t WHERE b = $1 AND c = $2"; got != want {
+		t.Fatalf("Postgres: got %q, want %q", got, want)
+	}
+}
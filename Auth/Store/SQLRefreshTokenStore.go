@@ -0,0 +1,132 @@
+package Store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SQLRefreshTokenStore is a database/sql implementation of RefreshTokenStore that also supports listing tokens
+// by subject and key binding. Run MigrateSQLSchema before using it.
+//
+// Only a SHA-256 hash of every token is stored; records returned by FindBySubject carry it as Token, and
+// Delete accepts it in place of the token.
+type SQLRefreshTokenStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+func NewSQLRefreshTokenStore(db *sql.DB, dialect SQLDialect) *SQLRefreshTokenStore {
+	return &SQLRefreshTokenStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// Save saves a refresh token and associated subject.
+func (store *SQLRefreshTokenStore) Save(refreshToken, subject string) error {
+	return store.SaveRecord(RefreshTokenRecord{
+		Token:    refreshToken,
+		Subject:  subject,
+		IssuedAt: time.Now(),
+	})
+}
+
+// SaveRecord saves a refresh token with its client and binding.
+func (store *SQLRefreshTokenStore) SaveRecord(record RefreshTokenRecord) error {
+	return store.insert(store.db, record)
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (store *SQLRefreshTokenStore) insert(db sqlExecer, record RefreshTokenRecord) error {
+	_, err := db.Exec(store.dialect.rebind("INSERT INTO refresh_tokens (token_hash, subject, client_id, issued_at, binding) VALUES (?, ?, ?, ?, ?)"),
+		hashRefreshToken(record.Token), record.Subject, record.ClientID, record.IssuedAt.UnixMilli(), record.Binding)
+	return err
+}
+
+// FindRecord retrieves the record of a refresh token.
+func (store *SQLRefreshTokenStore) FindRecord(refreshToken string) (*RefreshTokenRecord, error) {
+	records, err := store.query("SELECT token_hash, subject, client_id, issued_at, binding FROM refresh_tokens WHERE token_hash = ?", hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("refresh token not found")
+	}
+
+	records[0].Token = refreshToken
+	return &records[0], nil
+}
+
+// FindSubject retrieves the subject associated with a refresh token.
+func (store *SQLRefreshTokenStore) FindSubject(refreshToken string) (string, error) {
+	record, err := store.FindRecord(refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return record.Subject, nil
+}
+
+// FindBySubject retrieves all refresh tokens issued to a subject, oldest first.
+func (store *SQLRefreshTokenStore) FindBySubject(subject string) ([]RefreshTokenRecord, error) {
+	return store.query("SELECT token_hash, subject, client_id, issued_at, binding FROM refresh_tokens WHERE subject = ? ORDER BY issued_at", subject)
+}
+
+func (store *SQLRefreshTokenStore) query(query string, args ...any) ([]RefreshTokenRecord, error) {
+	rows, err := store.db.Query(store.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]RefreshTokenRecord, 0)
+	for rows.Next() {
+		var record RefreshTokenRecord
+		var issuedAt int64
+		err = rows.Scan(&record.Token, &record.Subject, &record.ClientID, &issuedAt, &record.Binding)
+		if err != nil {
+			return nil, err
+		}
+		record.IssuedAt = time.UnixMilli(issuedAt)
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Rotate replaces a refresh token by a new one in a single transaction.
+func (store *SQLRefreshTokenStore) Rotate(oldRefreshToken string, record RefreshTokenRecord) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(store.dialect.rebind("DELETE FROM refresh_tokens WHERE token_hash = ?"), hashRefreshToken(oldRefreshToken))
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("refresh token not found")
+	}
+
+	err = store.insert(tx, record)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a refresh token, given as the token or its hash, from storage.
+func (store *SQLRefreshTokenStore) Delete(refreshToken string) error {
+	_, err := store.db.Exec(store.dialect.rebind("DELETE FROM refresh_tokens WHERE token_hash = ?"), refreshTokenHandle(refreshToken))
+	return err
+}
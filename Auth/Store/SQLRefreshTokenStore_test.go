@@ -0,0 +1,111 @@
+package Store
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSQLRefreshTokenStoreRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	store := NewSQLRefreshTokenStore(db, SQLiteDialect)
+
+	// Issued a while ago, so it sorts before tokens saved within the same millisecond
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	err := store.SaveRecord(RefreshTokenRecord{Token: "token-1", Subject: "alice", ClientID: "app", IssuedAt: issuedAt, Binding: "jkt"})
+	if err != nil {
+		t.Fatalf("SaveRecord: %v", err)
+	}
+
+	record, err := store.FindRecord("token-1")
+	if err != nil {
+		t.Fatalf("FindRecord: %v", err)
+	}
+	want := RefreshTokenRecord{Token: "token-1", Subject: "alice", ClientID: "app", IssuedAt: issuedAt, Binding: "jkt"}
+	if *record != want {
+		t.Fatalf("got %+v, want %+v", *record, want)
+	}
+
+	var storedToken string
+	err = db.QueryRow("SELECT token_hash FROM refresh_tokens").Scan(&storedToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedToken == "token-1" || !strings.HasPrefix(storedToken, hashedRefreshTokenPrefix) {
+		t.Fatalf("got stored token %q, want a hash", storedToken)
+	}
+	_, err = store.FindRecord(storedToken)
+	if err == nil {
+		t.Fatal("FindRecord accepted the stored hash in place of the token")
+	}
+
+	err = store.Save("token-2", "alice")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	records, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatalf("FindBySubject: %v", err)
+	}
+	if len(records) != 2 || records[0].Token != storedToken {
+		t.Fatalf("got %+v, want both tokens, oldest first", records)
+	}
+
+	// Records of FindBySubject carry the hash, which must be enough to revoke them
+	for _, record := range records {
+		err = store.Delete(record.Token)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	records, _ = store.FindBySubject("alice")
+	if len(records) != 0 {
+		t.Fatalf("got %d tokens after deleting all of them", len(records))
+	}
+}
+
+func TestSQLRefreshTokenStoreRotate(t *testing.T) {
+	store := NewSQLRefreshTokenStore(newTestDB(t), SQLiteDialect)
+	err := store.Save("token-0", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Rotate("unknown", RefreshTokenRecord{Token: "token-x", Subject: "alice"})
+	if err == nil {
+		t.Fatal("Rotate of an unknown token succeeded")
+	}
+	if _, err := store.FindSubject("token-x"); err == nil {
+		t.Fatal("failed Rotate saved the new token")
+	}
+
+	// Only one of several concurrent rotations of the same token may succeed
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for _, newToken := range []string{"token-a", "token-b", "token-c", "token-d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Rotate("token-0", RefreshTokenRecord{Token: newToken, Subject: "alice", IssuedAt: time.Now()}) == nil {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if wins.Load() != 1 {
+		t.Fatalf("%d rotations succeeded, want 1", wins.Load())
+	}
+	if _, err := store.FindSubject("token-0"); err == nil {
+		t.Fatal("rotated token is still valid")
+	}
+	records, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d tokens, want 1", len(records))
+	}
+}
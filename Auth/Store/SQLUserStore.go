@@ -0,0 +1,217 @@
+package Store
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+	"time"
+)
+
+// SQLUserStore is a database/sql implementation of UserStore. Run MigrateSQLSchema before using it.
+type SQLUserStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+func NewSQLUserStore(db *sql.DB, dialect SQLDialect) *SQLUserStore {
+	return &SQLUserStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+func (store *SQLUserStore) FindUserByUsername(username string) (*model.User, error) {
+	user := &model.User{}
+	var accountExpiresAt, passwordChangedAt int64
+
+	err := store.db.QueryRow(store.dialect.rebind(`
+		SELECT username, id, password, disabled, locked, account_expires_at, credentials_expired,
+		       must_change_password, email_verified, password_changed_at
+		FROM users WHERE username = ?`), username).Scan(
+		&user.Username, &user.ID, &user.Password, &user.Disabled, &user.Locked, &accountExpiresAt,
+		&user.CredentialsExpired, &user.MustChangePassword, &user.EmailVerified, &passwordChangedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.AccountExpiresAt = fromUnixMilli(accountExpiresAt)
+	user.PasswordChangedAt = fromUnixMilli(passwordChangedAt)
+
+	user.Roles, err = store.queryStrings("SELECT role FROM user_roles WHERE username = ? ORDER BY role", username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PasswordHistory, err = store.queryStrings("SELECT password FROM password_history WHERE username = ? ORDER BY replaced_at DESC", username)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (store *SQLUserStore) SaveRefreshToken(username, refreshToken string) error {
+	_, err := store.db.Exec(store.dialect.rebind("INSERT INTO refresh_tokens (token_hash, subject, issued_at) VALUES (?, ?, ?)"),
+		hashRefreshToken(refreshToken), username, time.Now().UnixMilli())
+	return err
+}
+
+func (store *SQLUserStore) ValidateRefreshToken(refreshToken string) (string, error) {
+	var username string
+	err := store.db.QueryRow(store.dialect.rebind("SELECT subject FROM refresh_tokens WHERE token_hash = ?"), hashRefreshToken(refreshToken)).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("invalid refresh token")
+	}
+	return username, err
+}
+
+func (store *SQLUserStore) UpdatePassword(username, encodedPassword string) error {
+	result, err := store.db.Exec(store.dialect.rebind("UPDATE users SET password = ? WHERE username = ?"), encodedPassword, username)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (store *SQLUserStore) ChangePassword(username, encodedPassword string) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+
+	_, err = tx.Exec(store.dialect.rebind("INSERT INTO password_history (username, password, replaced_at) SELECT username, password, CAST(? AS BIGINT) FROM users WHERE username = ?"),
+		now, username)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(store.dialect.rebind(`
+		UPDATE users SET password = ?, password_changed_at = ?, must_change_password = ?, credentials_expired = ?
+		WHERE username = ?`), encodedPassword, now, false, false, username)
+	if err != nil {
+		return err
+	}
+	err = requireRowAffected(result)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(store.dialect.rebind(`
+		DELETE FROM password_history WHERE username = ? AND password NOT IN (
+			SELECT password FROM password_history WHERE username = ? ORDER BY replaced_at DESC LIMIT ?
+		)`), username, username, passwordHistoryLimit)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *SQLUserStore) MarkEmailVerified(username string) error {
+	result, err := store.db.Exec(store.dialect.rebind("UPDATE users SET email_verified = ? WHERE username = ?"), true, username)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+// SaveUser creates or replaces a user together with their roles. The password history is left untouched.
+func (store *SQLUserStore) SaveUser(user *model.User) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(store.dialect.rebind(`
+		INSERT INTO users (username, id, password, disabled, locked, account_expires_at, credentials_expired,
+		                   must_change_password, email_verified, password_changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET
+			id = excluded.id, password = excluded.password, disabled = excluded.disabled, locked = excluded.locked,
+			account_expires_at = excluded.account_expires_at, credentials_expired = excluded.credentials_expired,
+			must_change_password = excluded.must_change_password, email_verified = excluded.email_verified,
+			password_changed_at = excluded.password_changed_at`),
+		user.Username, user.ID, user.Password, user.Disabled, user.Locked, toUnixMilli(user.AccountExpiresAt),
+		user.CredentialsExpired, user.MustChangePassword, user.EmailVerified, toUnixMilli(user.PasswordChangedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(store.dialect.rebind("DELETE FROM user_roles WHERE username = ?"), user.Username)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range user.Roles {
+		_, err = tx.Exec(store.dialect.rebind("INSERT INTO user_roles (username, role) VALUES (?, ?)"), user.Username, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *SQLUserStore) AddUser(username string, password string) error {
+	return store.SaveUser(&model.User{
+		Username:          username,
+		Password:          password,
+		PasswordChangedAt: time.Now(),
+	})
+}
+
+func (store *SQLUserStore) queryStrings(query string, args ...any) ([]string, error) {
+	rows, err := store.db.Query(store.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([]string, 0)
+	for rows.Next() {
+		var value string
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// requireRowAffected turns an update of no row into model.ErrUserNotFound.
+func requireRowAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+	return nil
+}
+
+// toUnixMilli stores the zero time, meaning "never" or "unknown", as 0.
+func toUnixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
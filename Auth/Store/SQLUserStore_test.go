@@ -0,0 +1,92 @@
+package Store
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ayushs-2k4/go-security/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSQLUserStoreRoundTrip(t *testing.T) {
+	store := NewSQLUserStore(newTestDB(t), SQLiteDialect)
+
+	accountExpiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	err := store.SaveUser(&model.User{
+		Username:           "alice",
+		ID:                 "42",
+		Password:           "{noop}password",
+		Roles:              []string{"admin", "reader"},
+		Locked:             true,
+		AccountExpiresAt:   accountExpiresAt,
+		MustChangePassword: true,
+	})
+	if err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+
+	user, err := store.FindUserByUsername("alice")
+	if err != nil {
+		t.Fatalf("FindUserByUsername: %v", err)
+	}
+	if user.ID != "42" || user.Password != "{noop}password" || !user.Locked || !user.MustChangePassword ||
+		!user.AccountExpiresAt.Equal(accountExpiresAt) {
+		t.Fatalf("got %+v, want the saved user", user)
+	}
+	slices.Sort(user.Roles)
+	if !slices.Equal(user.Roles, []string{"admin", "reader"}) {
+		t.Fatalf("got roles %v, want [admin reader]", user.Roles)
+	}
+
+	err = store.MarkEmailVerified("alice")
+	if err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	user, _ = store.FindUserByUsername("alice")
+	if !user.EmailVerified {
+		t.Fatal("email not verified after MarkEmailVerified")
+	}
+
+	_, err = store.FindUserByUsername("bob")
+	if !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("unknown user: got %v, want %v", err, model.ErrUserNotFound)
+	}
+	err = store.UpdatePassword("bob", "{noop}password")
+	if !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("UpdatePassword of an unknown user: got %v, want %v", err, model.ErrUserNotFound)
+	}
+}
+
+func TestSQLUserStoreChangePasswordPrunesHistory(t *testing.T) {
+	store := NewSQLUserStore(newTestDB(t), SQLiteDialect)
+	err := store.AddUser("alice", "{noop}password 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const changes = passwordHistoryLimit + 5
+	for i := 1; i <= changes; i++ {
+		time.Sleep(2 * time.Millisecond) // History is ordered by millisecond timestamps
+		err = store.ChangePassword("alice", fmt.Sprintf("{noop}password %d", i))
+		if err != nil {
+			t.Fatalf("ChangePassword: %v", err)
+		}
+	}
+
+	user, err := store.FindUserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != fmt.Sprintf("{noop}password %d", changes) || user.MustChangePassword || user.PasswordChangedAt.IsZero() {
+		t.Fatalf("got %+v, want the last password", user)
+	}
+
+	want := make([]string, 0, passwordHistoryLimit)
+	for i := changes - 1; i >= changes-passwordHistoryLimit; i-- {
+		want = append(want, fmt.Sprintf("{noop}password %d", i))
+	}
+	if !slices.Equal(user.PasswordHistory, want) {
+		t.Fatalf("got history %v, want %v", user.PasswordHistory, want)
+	}
+}
@@ -0,0 +1,139 @@
+package Store
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+	"maps"
+	"sync"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists server-side sessions.
+type SessionStore interface {
+	// Save creates or replaces a session.
+	Save(session *model.Session) error
+	Find(id string) (*model.Session, error)
+	// Touch records an access to an existing session, failing with ErrSessionNotFound if it was deleted, so
+	// a concurrent logout can't be undone.
+	Touch(id string, lastAccessedAt, expiresAt time.Time) error
+	// SetAttribute sets one attribute of an existing session, failing with ErrSessionNotFound like Touch.
+	SetAttribute(id, name, value string) error
+	Delete(id string) error
+}
+
+// SubjectIndexedSessionStore is implemented by session stores that can list the sessions of a subject,
+// which is needed to sign a user out everywhere.
+type SubjectIndexedSessionStore interface {
+	SessionStore
+	FindBySubject(subject string) ([]*model.Session, error)
+}
+
+// InMemorySessionStore is a simple in-memory implementation of SessionStore.
+type InMemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*model.Session
+}
+
+// NewInMemorySessionStore creates a new instance of InMemorySessionStore.
+func NewInMemorySessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{
+		sessions: make(map[string]*model.Session),
+	}
+}
+
+// Save stores a copy of the session, so callers can't mutate stored state without saving again.
+func (store *InMemorySessionStore) Save(session *model.Session) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Drop expired sessions so the store doesn't grow without bound
+	now := time.Now()
+	for id, stored := range store.sessions {
+		if isSessionExpired(stored, now) {
+			delete(store.sessions, id)
+		}
+	}
+
+	store.sessions[session.ID] = copySession(session)
+	return nil
+}
+
+// Find retrieves a copy of the session with the given ID.
+func (store *InMemorySessionStore) Find(id string) (*model.Session, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	session, ok := store.sessions[id]
+	if !ok || isSessionExpired(session, time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+// Touch updates when a session was last accessed and when it expires.
+func (store *InMemorySessionStore) Touch(id string, lastAccessedAt, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	session, ok := store.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	session.LastAccessedAt = lastAccessedAt
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+// SetAttribute sets one attribute of a session.
+func (store *InMemorySessionStore) SetAttribute(id, name, value string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	session, ok := store.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	if session.Attributes == nil {
+		session.Attributes = make(map[string]string)
+	}
+	session.Attributes[name] = value
+	return nil
+}
+
+// Delete removes a session from storage.
+func (store *InMemorySessionStore) Delete(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.sessions, id)
+	return nil
+}
+
+// FindBySubject retrieves copies of all sessions of a subject.
+func (store *InMemorySessionStore) FindBySubject(subject string) ([]*model.Session, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	sessions := make([]*model.Session, 0)
+	now := time.Now()
+	for _, session := range store.sessions {
+		if session.Subject == subject && !isSessionExpired(session, now) {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	return sessions, nil
+}
+
+func isSessionExpired(session *model.Session, now time.Time) bool {
+	return !session.ExpiresAt.IsZero() && now.After(session.ExpiresAt)
+}
+
+func copySession(session *model.Session) *model.Session {
+	sessionCopy := *session
+	sessionCopy.Attributes = maps.Clone(session.Attributes)
+	return &sessionCopy
+}
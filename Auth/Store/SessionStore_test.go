@@ -0,0 +1,72 @@
+package Store
+
+import (
+	"errors"
+	"github.com/ayushs-2k4/go-security/model"
+	"testing"
+	"time"
+)
+
+func TestInMemorySessionStoreUpdatesOnlyExistingSessions(t *testing.T) {
+	store := NewInMemorySessionStore()
+	now := time.Now()
+
+	err := store.Touch("deleted", now, now.Add(time.Hour))
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Touch: got error %v, want %v", err, ErrSessionNotFound)
+	}
+	err = store.SetAttribute("deleted", "name", "value")
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("SetAttribute: got error %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := store.Find("deleted"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("updating a missing session created it: Find returned %v", err)
+	}
+
+	_ = store.Save(&model.Session{ID: "session", Subject: "alice", ExpiresAt: now.Add(time.Minute)})
+	err = store.Touch("session", now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SetAttribute("session", "name", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := store.Find("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.ExpiresAt.Equal(now.Add(time.Hour)) || session.Attributes["name"] != "value" {
+		t.Fatalf("got session %+v, want it touched and its attribute set", session)
+	}
+
+	// Found sessions are copies
+	session.Attributes["name"] = "changed"
+	if stored, _ := store.Find("session"); stored.Attributes["name"] != "value" {
+		t.Fatal("changing a found session changed the stored one")
+	}
+}
+
+func TestInMemorySessionStoreDropsExpiredSessions(t *testing.T) {
+	store := NewInMemorySessionStore()
+	now := time.Now()
+
+	_ = store.Save(&model.Session{ID: "expired", Subject: "alice", ExpiresAt: now.Add(-time.Second)})
+	if _, err := store.Find("expired"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Find: got error %v, want %v", err, ErrSessionNotFound)
+	}
+
+	_ = store.Save(&model.Session{ID: "active", Subject: "alice", ExpiresAt: now.Add(time.Hour)})
+	if _, ok := store.sessions["expired"]; ok {
+		t.Fatal("Save kept the expired session")
+	}
+
+	sessions, err := store.FindBySubject("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "active" {
+		t.Fatalf("got sessions %v, want only the active one", sessions)
+	}
+}
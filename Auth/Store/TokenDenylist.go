@@ -0,0 +1,51 @@
+package Store
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenDenylist records revoked JWT IDs (jti) until the tokens would have expired anyway.
+type TokenDenylist interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// InMemoryTokenDenylist is a simple in-memory implementation of TokenDenylist.
+type InMemoryTokenDenylist struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewInMemoryTokenDenylist creates a new instance of InMemoryTokenDenylist.
+func NewInMemoryTokenDenylist() *InMemoryTokenDenylist {
+	return &InMemoryTokenDenylist{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// Revoke adds a JWT ID to the denylist until expiresAt.
+func (store *InMemoryTokenDenylist) Revoke(jti string, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Entries past their expiry are useless, the token is rejected for being expired
+	now := time.Now()
+	for id, expiry := range store.revoked {
+		if now.After(expiry) {
+			delete(store.revoked, id)
+		}
+	}
+
+	store.revoked[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether a JWT ID has been revoked.
+func (store *InMemoryTokenDenylist) IsRevoked(jti string) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	_, ok := store.revoked[jti]
+	return ok, nil
+}
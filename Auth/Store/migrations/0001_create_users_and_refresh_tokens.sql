@@ -0,0 +1,36 @@
+CREATE TABLE users (
+    username             VARCHAR(255) PRIMARY KEY,
+    id                   VARCHAR(255) NOT NULL DEFAULT '',
+    password             VARCHAR(255) NOT NULL,
+    disabled             BOOLEAN      NOT NULL DEFAULT FALSE,
+    locked               BOOLEAN      NOT NULL DEFAULT FALSE,
+    account_expires_at   BIGINT       NOT NULL DEFAULT 0,
+    credentials_expired  BOOLEAN      NOT NULL DEFAULT FALSE,
+    must_change_password BOOLEAN      NOT NULL DEFAULT FALSE,
+    email_verified       BOOLEAN      NOT NULL DEFAULT FALSE,
+    password_changed_at  BIGINT       NOT NULL DEFAULT 0
+);
+
+CREATE TABLE user_roles (
+    username VARCHAR(255) NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    role     VARCHAR(255) NOT NULL,
+    PRIMARY KEY (username, role)
+);
+
+CREATE TABLE password_history (
+    username    VARCHAR(255) NOT NULL REFERENCES users (username) ON DELETE CASCADE,
+    password    VARCHAR(255) NOT NULL,
+    replaced_at BIGINT       NOT NULL
+);
+
+CREATE INDEX password_history_username ON password_history (username, replaced_at);
+
+CREATE TABLE refresh_tokens (
+    token_hash VARCHAR(255) PRIMARY KEY,
+    subject    VARCHAR(255) NOT NULL,
+    client_id  VARCHAR(255) NOT NULL DEFAULT '',
+    issued_at  BIGINT       NOT NULL,
+    binding    VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX refresh_tokens_subject ON refresh_tokens (subject, issued_at);
@@ -0,0 +1,327 @@
+package Auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"log"
+	"net/http"
+	"time"
+)
+
+const maxRequestBodySize = 1 << 20
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenResponse struct {
+	JWTToken     string `json:"jwtToken"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// PasswordChangeRequired is set when JWTToken only grants access to the password change endpoint.
+	PasswordChangeRequired bool `json:"passwordChangeRequired,omitempty"`
+}
+
+// ErrorResponse is the body of every error returned by the handlers in this package.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+
+	// Violations lists the broken rules when a new password is rejected by a PasswordPolicy.
+	Violations []PolicyViolation `json:"violations,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
+
+// decodeJSONRequest decodes a POSTed JSON body into v, writing the error response itself when it fails.
+func decodeJSONRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST is allowed")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Malformed JSON body")
+		return false
+	}
+
+	return true
+}
+
+// ************************************************************************ //
+
+// LoginHandler exchanges a username and password for a JWT and refresh token.
+type LoginHandler struct {
+	credentialVerifier *CredentialVerifier
+	secret             []byte
+	expiry             time.Duration
+	refreshTokenStore  Store.RefreshTokenStore
+	tokenOptions       []TokenOption
+}
+
+func NewLoginHandler(userStore model.UserStore, secret []byte, expiry time.Duration, refreshTokenStore Store.RefreshTokenStore) *LoginHandler {
+	return &LoginHandler{
+		credentialVerifier: NewCredentialVerifier(userStore),
+		secret:             secret,
+		expiry:             expiry,
+		refreshTokenStore:  refreshTokenStore,
+	}
+}
+
+// WithCredentialVerifier replaces the verifier, e.g. to use a different PasswordEncoder.
+func (h *LoginHandler) WithCredentialVerifier(credentialVerifier *CredentialVerifier) *LoginHandler {
+	h.credentialVerifier = credentialVerifier
+	return h
+}
+
+// WithTokenOptions adds options, such as WithSessionLimit, applied to every issued token pair.
+func (h *LoginHandler) WithTokenOptions(opts ...TokenOption) *LoginHandler {
+	h.tokenOptions = append(h.tokenOptions, opts...)
+	return h
+}
+
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var loginReq LoginRequest
+	if !decodeJSONRequest(w, r, &loginReq) {
+		return
+	}
+
+	user, err := h.credentialVerifier.Verify(r, loginReq.Username, loginReq.Password)
+	var throttledErr *LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		throttledErr.setRetryAfter(w)
+		writeError(w, http.StatusTooManyRequests, "too_many_attempts", "Too many failed logins, try again later")
+		return
+	}
+	if code, ok := accountStatusErrorCode(err); ok {
+		writeError(w, http.StatusForbidden, code, err.Error())
+		return
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		// Same answer for unknown users and wrong passwords, so accounts can't be enumerated
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", "Invalid username or password")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify credentials: %v", err)
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to verify credentials")
+		return
+	}
+
+	if user.MustChangePassword {
+		h.issuePasswordChangeToken(w, user.Username)
+		return
+	}
+
+	jwtToken, refreshToken, err := GenerateJWT(user.Username, h.secret, h.expiry, h.refreshTokenStore, h.tokenOptions...)
+	if errors.Is(err, ErrSessionLimitReached) {
+		writeError(w, http.StatusForbidden, "session_limit_reached", "Maximum number of active sessions reached")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to issue tokens")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		JWTToken:     jwtToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// issuePasswordChangeToken answers a login that must be followed by a password change with a restricted token.
+func (h *LoginHandler) issuePasswordChangeToken(w http.ResponseWriter, username string) {
+	jwtToken, err := GeneratePasswordChangeJWT(username, h.secret, h.expiry)
+	if err != nil {
+		log.Printf("Failed to issue password change token: %v", err)
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to issue tokens")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		JWTToken:               jwtToken,
+		PasswordChangeRequired: true,
+	})
+}
+
+// ************************************************************************ //
+
+// RefreshHandler exchanges a refresh token for a new token pair, rotating the refresh token.
+type RefreshHandler struct {
+	secret            []byte
+	expiry            time.Duration
+	refreshTokenStore Store.RefreshTokenStore
+	accessTokenStore  Store.AccessTokenStore
+	tokenOptions      []TokenOption
+}
+
+func NewRefreshHandler(secret []byte, expiry time.Duration, refreshTokenStore Store.RefreshTokenStore) *RefreshHandler {
+	return &RefreshHandler{
+		secret:            secret,
+		expiry:            expiry,
+		refreshTokenStore: refreshTokenStore,
+	}
+}
+
+// WithTokenOptions adds options applied to every issued token pair.
+func (h *RefreshHandler) WithTokenOptions(opts ...TokenOption) *RefreshHandler {
+	h.tokenOptions = append(h.tokenOptions, opts...)
+	return h
+}
+
+// WithAccountStatusCheck refuses to refresh tokens of disabled, locked or expired accounts, and of users who must
+// change their password.
+func (h *RefreshHandler) WithAccountStatusCheck(userStore model.UserStore) *RefreshHandler {
+	return h.WithTokenOptions(WithAccountStatusCheck(userStore))
+}
+
+// WithOpaqueTokens issues opaque access tokens saved in accessTokenStore instead of JWTs, for clients that got
+// their tokens from GenerateOpaqueToken.
+func (h *RefreshHandler) WithOpaqueTokens(accessTokenStore Store.AccessTokenStore) *RefreshHandler {
+	h.accessTokenStore = accessTokenStore
+	return h
+}
+
+func (h *RefreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var refreshReq RefreshRequest
+	if !decodeJSONRequest(w, r, &refreshReq) {
+		return
+	}
+
+	if refreshReq.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Missing refresh token")
+		return
+	}
+
+	opts := append([]TokenOption{WithAccessTokenExpiry(h.expiry)}, h.tokenOptions...)
+	var jwtToken, refreshToken string
+	var err error
+	if h.accessTokenStore != nil {
+		jwtToken, refreshToken, err = RefreshOpaqueToken(refreshReq.RefreshToken, h.expiry, h.accessTokenStore, h.refreshTokenStore, opts...)
+	} else {
+		jwtToken, refreshToken, err = RefreshJWT(refreshReq.RefreshToken, h.secret, h.refreshTokenStore, opts...)
+	}
+	if code, ok := accountStatusErrorCode(err); ok {
+		writeError(w, http.StatusForbidden, code, err.Error())
+		return
+	}
+	if errors.Is(err, ErrPasswordChangeRequired) {
+		writeError(w, http.StatusForbidden, "password_change_required", "Log in again to change the password")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TokenResponse{
+		JWTToken:     jwtToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// ************************************************************************ //
+
+// LogoutHandler revokes the refresh token in the request body and, if configured, the JWT in the Authorization header.
+// The refresh token alone is enough to log out, so the handler can run without authentication, e.g. once the access
+// token expired. When the caller is also authenticated, the refresh token must belong to the same user.
+type LogoutHandler struct {
+	refreshTokenStore Store.RefreshTokenStore
+	secret            []byte
+	denylist          Store.TokenDenylist
+}
+
+func NewLogoutHandler(refreshTokenStore Store.RefreshTokenStore) *LogoutHandler {
+	return &LogoutHandler{
+		refreshTokenStore: refreshTokenStore,
+	}
+}
+
+// WithJWTDenylist also revokes the caller's JWT, signed with secret, until it expires.
+func (h *LogoutHandler) WithJWTDenylist(secret []byte, denylist Store.TokenDenylist) *LogoutHandler {
+	h.secret = secret
+	h.denylist = denylist
+	return h
+}
+
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var logoutReq LogoutRequest
+	if !decodeJSONRequest(w, r, &logoutReq) {
+		return
+	}
+
+	if _, tokenString := obtainAuthorization(r); logoutReq.RefreshToken == "" && tokenString == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Missing refresh token")
+		return
+	}
+
+	if logoutReq.RefreshToken != "" {
+		// Unknown refresh tokens are already logged out
+		subject, err := h.refreshTokenStore.FindSubject(logoutReq.RefreshToken)
+		if err == nil {
+			if callerSubject, ok := h.callerSubject(r); ok && callerSubject != subject {
+				writeError(w, http.StatusForbidden, "invalid_grant", "Refresh token was issued to another user")
+				return
+			}
+
+			err = RevokeRefreshToken(logoutReq.RefreshToken, h.refreshTokenStore)
+			if err != nil {
+				log.Printf("Failed to revoke refresh token: %v", err)
+				writeError(w, http.StatusInternalServerError, "server_error", "Failed to revoke refresh token")
+				return
+			}
+		}
+	}
+
+	if h.denylist != nil {
+		if _, tokenString := obtainAuthorization(r); tokenString != "" {
+			err := RevokeJWT(tokenString, h.secret, h.denylist)
+			if err != nil {
+				log.Printf("Failed to revoke JWT: %v", err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// callerSubject returns the authenticated user, or without authentication the subject of a valid JWT in the
+// Authorization header.
+func (h *LogoutHandler) callerSubject(r *http.Request) (string, bool) {
+	if principal := PrincipalFromRequest(r); principal != nil {
+		return principal.Subject, true
+	}
+
+	if h.secret == nil {
+		return "", false
+	}
+	_, tokenString := obtainAuthorization(r)
+	if tokenString == "" {
+		return "", false
+	}
+	claims, err := parseAccessToken(tokenString, h.secret)
+	if err != nil {
+		return "", false
+	}
+	return claims.Subject, true
+}
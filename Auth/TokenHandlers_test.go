@@ -0,0 +1,261 @@
+package Auth
+
+import (
+	"encoding/json"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// caseInsensitiveUserStore looks users up like a database with a case-insensitive username column.
+type caseInsensitiveUserStore struct {
+	*Store.InMemoryUserStore
+}
+
+func (s caseInsensitiveUserStore) FindUserByUsername(username string) (*model.User, error) {
+	return s.InMemoryUserStore.FindUserByUsername(strings.ToLower(strings.TrimSpace(username)))
+}
+
+func newTestUserStore(t *testing.T, users ...*model.User) caseInsensitiveUserStore {
+	t.Helper()
+
+	hashedPassword, err := NewDefaultPasswordEncoder().Encode("alice password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userStore := caseInsensitiveUserStore{Store.NewInMemoryUserStore()}
+	for _, user := range users {
+		user.Password = hashedPassword
+		userStore.SaveUser(user)
+	}
+	return userStore
+}
+
+// postJSON sends body to handler, with an Authorization header unless it is empty.
+func postJSON(handler http.Handler, path, authorization, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func decodeResponse[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var response T
+	err := json.NewDecoder(w.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+	return response
+}
+
+func TestLoginHandlerIssuesTokensForTheCanonicalUsername(t *testing.T) {
+	userStore := newTestUserStore(t, &model.User{Username: "alice"})
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	handler := NewLoginHandler(userStore, testSecret, time.Minute, refreshTokenStore)
+
+	w := postJSON(handler, "/login", "", `{"username":" Alice ","password":"alice password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	tokens := decodeResponse[TokenResponse](t, w)
+
+	claims, err := ParseJWT(tokens.JWTToken, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "alice" {
+		t.Fatalf("got access token subject %q, want %q", claims.Subject, "alice")
+	}
+
+	subject, err := refreshTokenStore.FindSubject(tokens.RefreshToken)
+	if err != nil || subject != "alice" {
+		t.Fatalf("got refresh token subject %q (%v), want %q", subject, err, "alice")
+	}
+}
+
+func TestLoginHandlerErrors(t *testing.T) {
+	userStore := newTestUserStore(t, &model.User{Username: "alice"}, &model.User{Username: "bob", Locked: true})
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	handler := NewLoginHandler(userStore, testSecret, time.Minute, refreshTokenStore).
+		WithTokenOptions(WithSessionLimit(NewSessionLimiter(1, RejectNewSession)))
+
+	if w := postJSON(handler, "/login", "", `{"username":"alice","password":"alice password"}`); w.Code != http.StatusOK {
+		t.Fatalf("first login: got status %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"wrong password", `{"username":"alice","password":"wrong"}`, http.StatusUnauthorized, "invalid_credentials"},
+		{"unknown user", `{"username":"mallory","password":"alice password"}`, http.StatusUnauthorized, "invalid_credentials"},
+		{"locked account", `{"username":"bob","password":"alice password"}`, http.StatusForbidden, "account_locked"},
+		{"session limit", `{"username":"alice","password":"alice password"}`, http.StatusForbidden, "session_limit_reached"},
+		{"malformed body", `{"username":`, http.StatusBadRequest, "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(handler, "/login", "", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if response := decodeResponse[ErrorResponse](t, w); response.Error != tt.code {
+				t.Fatalf("got error %q, want %q", response.Error, tt.code)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Fatalf("GET: got status %d with Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestLoginHandlerRestrictsUsersWhoMustChangePassword(t *testing.T) {
+	userStore := newTestUserStore(t, &model.User{Username: "alice", MustChangePassword: true})
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	handler := NewLoginHandler(userStore, testSecret, time.Minute, refreshTokenStore)
+
+	w := postJSON(handler, "/login", "", `{"username":"alice","password":"alice password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	tokens := decodeResponse[TokenResponse](t, w)
+	if !tokens.PasswordChangeRequired || tokens.RefreshToken != "" {
+		t.Fatalf("got %+v, want only a password change token", tokens)
+	}
+	if _, err := ParseJWT(tokens.JWTToken, testSecret); err == nil {
+		t.Fatal("the password change token is accepted as a regular access token")
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	userStore := newTestUserStore(t, &model.User{Username: "alice"})
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	handler := NewRefreshHandler(testSecret, time.Minute, refreshTokenStore).WithAccountStatusCheck(userStore)
+
+	_, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(handler, "/refresh", "", `{"refreshToken":"`+refreshToken+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: got status %d: %s", w.Code, w.Body)
+	}
+	tokens := decodeResponse[TokenResponse](t, w)
+	if claims, err := ParseJWT(tokens.JWTToken, testSecret); err != nil || claims.Subject != "alice" {
+		t.Fatalf("refreshed access token: got %+v (%v), want one of alice", claims, err)
+	}
+	if tokens.RefreshToken == refreshToken {
+		t.Fatal("the refresh token was not rotated")
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"reused refresh token", `{"refreshToken":"` + refreshToken + `"}`, http.StatusUnauthorized, "invalid_grant"},
+		{"unknown refresh token", `{"refreshToken":"unknown"}`, http.StatusUnauthorized, "invalid_grant"},
+		{"missing refresh token", `{}`, http.StatusBadRequest, "invalid_request"},
+		{"malformed body", `[`, http.StatusBadRequest, "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(handler, "/refresh", "", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if response := decodeResponse[ErrorResponse](t, w); response.Error != tt.code {
+				t.Fatalf("got error %q, want %q", response.Error, tt.code)
+			}
+		})
+	}
+
+	// Locking the account stops its refresh tokens from working
+	userStore.SaveUser(&model.User{Username: "alice", Locked: true})
+	w = postJSON(handler, "/refresh", "", `{"refreshToken":"`+tokens.RefreshToken+`"}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("locked account: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if response := decodeResponse[ErrorResponse](t, w); response.Error != "account_locked" {
+		t.Fatalf("locked account: got error %q, want %q", response.Error, "account_locked")
+	}
+}
+
+func TestLogoutHandler(t *testing.T) {
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+	denylist := Store.NewInMemoryTokenDenylist()
+	handler := NewLogoutHandler(refreshTokenStore).WithJWTDenylist(testSecret, denylist)
+
+	aliceAccessToken, aliceRefreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobAccessToken, _, err := GenerateJWT("bob", testSecret, time.Minute, refreshTokenStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody may log out somebody else's session
+	w := postJSON(handler, "/logout", "Bearer "+bobAccessToken, `{"refreshToken":"`+aliceRefreshToken+`"}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("logging out another user: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if _, err := refreshTokenStore.FindSubject(aliceRefreshToken); err != nil {
+		t.Fatal("another user's logout revoked the refresh token")
+	}
+
+	w = postJSON(handler, "/logout", "Bearer "+aliceAccessToken, `{"refreshToken":"`+aliceRefreshToken+`"}`)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("logout: got status %d: %s", w.Code, w.Body)
+	}
+	if _, err := refreshTokenStore.FindSubject(aliceRefreshToken); err == nil {
+		t.Fatal("the refresh token survived the logout")
+	}
+	claims, err := parseAccessToken(aliceAccessToken, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := denylist.IsRevoked(claims.Id); !revoked {
+		t.Fatal("the access token survived the logout")
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		status        int
+	}{
+		{"already revoked refresh token", "", `{"refreshToken":"` + aliceRefreshToken + `"}`, http.StatusNoContent},
+		{"only an access token", "Bearer " + bobAccessToken, `{}`, http.StatusNoContent},
+		{"no token at all", "", `{}`, http.StatusBadRequest},
+		{"malformed body", "", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := postJSON(handler, "/logout", tt.authorization, tt.body); w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
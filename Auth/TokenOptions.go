@@ -0,0 +1,97 @@
+package Auth
+
+import (
+	"crypto/x509"
+	"github.com/ayushs-2k4/go-security/model"
+	"time"
+)
+
+// TokenOption customizes how GenerateJWT and GenerateOpaqueToken issue a token pair.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	accessTokenExpiry time.Duration
+	sessionLimiter    *SessionLimiter
+	clientID          string
+	scope             string
+	dpopJKT           string
+	x5tS256           string
+	userStore         model.UserStore
+}
+
+// defaultAccessTokenExpiry is the lifetime of access tokens issued by RefreshJWT without WithAccessTokenExpiry.
+const defaultAccessTokenExpiry = 72 * time.Hour
+
+func newTokenOptions(opts []TokenOption) *tokenOptions {
+	options := &tokenOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// accessTokenExpiryOr returns the lifetime set with WithAccessTokenExpiry, or fallback if none was set.
+func (options *tokenOptions) accessTokenExpiryOr(fallback time.Duration) time.Duration {
+	if options.accessTokenExpiry > 0 {
+		return options.accessTokenExpiry
+	}
+	return fallback
+}
+
+// WithAccessTokenExpiry sets the lifetime of the access token, overriding the expiry passed to GenerateJWT or
+// GenerateOpaqueToken. RefreshJWT issues tokens valid for 72 hours without it.
+func WithAccessTokenExpiry(expiry time.Duration) TokenOption {
+	return func(options *tokenOptions) {
+		options.accessTokenExpiry = expiry
+	}
+}
+
+// WithSessionLimit enforces the limiter's maximum number of active sessions before a new refresh token is issued.
+func WithSessionLimit(limiter *SessionLimiter) TokenOption {
+	return func(options *tokenOptions) {
+		options.sessionLimiter = limiter
+	}
+}
+
+// WithClientID records the OAuth client the token is issued to.
+func WithClientID(clientID string) TokenOption {
+	return func(options *tokenOptions) {
+		options.clientID = clientID
+	}
+}
+
+// WithScope records the space-separated scopes granted to the token.
+func WithScope(scope string) TokenOption {
+	return func(options *tokenOptions) {
+		options.scope = scope
+	}
+}
+
+// WithDPoPKeyThumbprint binds the access and refresh token to the client key with the given RFC 7638 thumbprint,
+// as returned by DPoPVerifier.VerifyProof.
+func WithDPoPKeyThumbprint(jkt string) TokenOption {
+	return func(options *tokenOptions) {
+		options.dpopJKT = jkt
+	}
+}
+
+// WithAccountStatusCheck makes RefreshJWT look up the user in userStore and refuse to refresh tokens of disabled,
+// locked or expired accounts, and of users who must change their password, who have to log in again for a
+// password change token.
+func WithAccountStatusCheck(userStore model.UserStore) TokenOption {
+	return func(options *tokenOptions) {
+		options.userStore = userStore
+	}
+}
+
+// WithCertificateBinding binds the access and refresh token to the mutual TLS client certificate they are issued
+// to (RFC 8705). A nil certificate, as returned by ObtainClientCertificate without mutual TLS, leaves them unbound.
+func WithCertificateBinding(certificate *x509.Certificate) TokenOption {
+	return func(options *tokenOptions) {
+		if certificate == nil {
+			options.x5tS256 = ""
+			return
+		}
+		options.x5tS256 = CertificateThumbprint(certificate)
+	}
+}
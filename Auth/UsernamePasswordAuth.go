@@ -2,40 +2,50 @@ package Auth
 
 import (
 	"github.com/ayushs-2k4/go-security/model"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
 )
 
 type UsernamePasswordAuth struct {
-	userStore model.UserStore
+	credentialVerifier *CredentialVerifier
 }
 
 func NewPasswordAuth(userStore model.UserStore) *UsernamePasswordAuth {
 	return &UsernamePasswordAuth{
-		userStore: userStore,
+		credentialVerifier: NewCredentialVerifier(userStore),
 	}
 }
 
+// WithCredentialVerifier replaces the verifier, e.g. to use a different PasswordEncoder.
+func (p *UsernamePasswordAuth) WithCredentialVerifier(credentialVerifier *CredentialVerifier) *UsernamePasswordAuth {
+	p.credentialVerifier = credentialVerifier
+	return p
+}
+
 func (p *UsernamePasswordAuth) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
 	// For simplicity, we use query params (use headers/body for actual cases).
 	username := ObtainUsernameFromHeader(r)
 	password := ObtainPasswordFromHeader(r)
-
-	user, err := p.userStore.FindUserByUsername(username)
-
-	if err != nil || user == nil {
-		return false, err
+	if username == "" {
+		return false, nil // Not a username/password request, leave it to the other authentication methods
 	}
 
-	// Password check (in a real scenario, you'd retrieve the hash from a DB)
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	user, err := p.credentialVerifier.Verify(r, username, password)
 	if err != nil {
-		return false, ErrInvalidCredentials
+		return false, err
 	}
 
+	setPrincipal(r, &Principal{
+		Subject:            user.Username,
+		PasswordChangeOnly: user.MustChangePassword,
+	})
+
 	return true, nil
 }
 
+func (p *UsernamePasswordAuth) provideUserStore() model.UserStore {
+	return p.credentialVerifier.userStore
+}
+
 func ObtainUsernameFromHeader(r *http.Request) string {
 	return r.Header.Get("X-Username")
 }
@@ -3,12 +3,69 @@ package Auth
 import (
 	"errors"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrAuthenticationFailed = errors.New("authentication failed")
 )
 
 type AuthMethod interface {
 	Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) // returns true if successful
 }
+
+// EntryPoint answers a request that failed authentication, for example with a 401 or a redirect to a login page.
+type EntryPoint interface {
+	Commence(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Challenger is implemented by auth methods that tell the client how to authenticate,
+// typically through a WWW-Authenticate header, when a request is rejected.
+type Challenger interface {
+	Challenge(w http.ResponseWriter)
+}
+
+// externalURL returns the URL clients used to reach r. Behind a TLS-terminating proxy the request only shows the
+// proxy's connection, so the configured public base URL takes precedence when set.
+func externalURL(r *http.Request, base *url.URL) *url.URL {
+	if base != nil {
+		return &url.URL{
+			Scheme: base.Scheme,
+			Host:   base.Host,
+			Path:   strings.TrimSuffix(base.Path, "/") + r.URL.Path,
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path}
+}
+
+// ObtainBearerToken returns the token from the Authorization header, with an optional "Bearer " scheme stripped.
+func ObtainBearerToken(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+
+	scheme, token, found := strings.Cut(authorization, " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return authorization
+}
+
+// obtainAuthorization splits the Authorization header into its scheme and credentials.
+// A header without a scheme is returned as credentials with an empty scheme.
+func obtainAuthorization(r *http.Request) (string, string) {
+	authorization := r.Header.Get("Authorization")
+
+	scheme, credentials, found := strings.Cut(authorization, " ")
+	if !found {
+		return "", authorization
+	}
+
+	return scheme, strings.TrimSpace(credentials)
+}
@@ -0,0 +1,87 @@
+package Auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestJWKThumbprintRFC7638Example(t *testing.T) {
+	key := &JWK{
+		Kty: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajr" +
+			"n1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+
+	thumbprint, err := key.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != want {
+		t.Fatalf("got thumbprint %q, want %q", thumbprint, want)
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, publicKey := range []any{&ecKey.PublicKey, &rsaKey.PublicKey} {
+		jwk, err := NewJWK(publicKey)
+		if err != nil {
+			t.Fatalf("NewJWK(%T): %v", publicKey, err)
+		}
+
+		decoded, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey of %T: %v", publicKey, err)
+		}
+		if !decoded.(interface{ Equal(x crypto.PublicKey) bool }).Equal(publicKey) {
+			t.Fatalf("%T does not survive the round trip", publicKey)
+		}
+	}
+}
+
+func TestJWKPublicKeyRejectsUnsafeKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, _ := NewJWK(&ecKey.PublicKey)
+
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, _ := NewJWK(&smallRSAKey.PublicKey)
+
+	withPrivateKey := *valid
+	withPrivateKey.D = "AQAB"
+	offCurve := *valid
+	offCurve.Y = offCurve.X
+	unknownCurve := *valid
+	unknownCurve.Crv = "secp256k1"
+
+	for name, jwk := range map[string]*JWK{
+		"private key":      &withPrivateKey,
+		"point off curve":  &offCurve,
+		"unknown curve":    &unknownCurve,
+		"small RSA key":    small,
+		"unknown key type": {Kty: "oct"},
+	} {
+		if _, err := jwk.PublicKey(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -2,41 +2,71 @@ package Auth
 
 import (
 	"errors"
+	"fmt"
 	"github.com/ayushs-2k4/go-security/Auth/Store"
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
+var (
+	ErrTokenRevoked    = errors.New("token has been revoked")
+	ErrRestrictedToken = errors.New("token is restricted to changing the password")
+	ErrNotAccessToken  = errors.New("not an access token")
+)
+
 type Claim struct {
-	Email string `json:"email"`
+	Email        string        `json:"email,omitempty"`
+	Scope        string        `json:"scope,omitempty"`
+	ClientID     string        `json:"client_id,omitempty"`
+	Confirmation *Confirmation `json:"cnf,omitempty"`
 	jwt.StandardClaims
 }
 
-func GenerateJWT(subject string, secret []byte, expiry time.Duration, refreshTokenStore Store.RefreshTokenStore) (string, string, error) {
-	// Generate JWT
-	jwtToken, err := generateJWTOnly(subject, secret, expiry)
-	if err != nil {
-		return "", "", err
-	}
+func GenerateJWT(subject string, secret []byte, expiry time.Duration, refreshTokenStore Store.RefreshTokenStore, opts ...TokenOption) (string, string, error) {
+	options := newTokenOptions(opts)
 
-	// Generate Refresh Token
-	refreshToken, err := GenerateRefreshToken(subject, refreshTokenStore)
-	if err != nil {
-		return "", "", err
-	}
+	// Enforce the session limit before issuing anything
+	return options.sessionLimiter.issue(subject, refreshTokenStore, func() (string, string, error) {
+		// Generate JWT
+		jwtToken, err := generateJWTOnly(subject, secret, options.accessTokenExpiryOr(expiry), options)
+		if err != nil {
+			return "", "", err
+		}
+
+		// Generate Refresh Token
+		refreshToken, err := generateRefreshTokenOnly(subject, refreshTokenStore, options)
+		if err != nil {
+			return "", "", err
+		}
 
-	return jwtToken, refreshToken, nil
+		return jwtToken, refreshToken, nil
+	})
 }
 
-func generateJWTOnly(subject string, secret []byte, expiry time.Duration) (string, error) {
-	expirationTime := time.Now().Add(expiry)
+func generateJWTOnly(subject string, secret []byte, expiry time.Duration, options *tokenOptions) (string, error) {
+	now := time.Now()
+	expirationTime := now.Add(expiry)
+
+	claims := &Claim{
+		Scope:    options.scope,
+		ClientID: options.clientID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(), // jti, lets a single token be revoked
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expirationTime.Unix(),
+			Subject:   subject,
+		},
+	}
 
-	claims := &jwt.StandardClaims{
-		ExpiresAt: expirationTime.Unix(),
-		Subject:   subject,
+	if options.dpopJKT != "" || options.x5tS256 != "" {
+		claims.Confirmation = &Confirmation{
+			JKT:     options.dpopJKT,
+			X5TS256: options.x5tS256,
+		}
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -50,26 +80,119 @@ func generateJWTOnly(subject string, secret []byte, expiry time.Duration) (strin
 }
 
 func ValidateJWT(tokenString string, secret []byte) (bool, error) {
+	_, err := ParseJWT(tokenString, secret)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ParseJWT validates an access token and returns its claims. Other tokens signed with the same secret, such as
+// email verification tokens, are rejected with ErrNotAccessToken, and tokens restricted to PasswordChangeScope,
+// which AuthChain only accepts on the password change path, with ErrRestrictedToken.
+func ParseJWT(tokenString string, secret []byte) (*Claim, error) {
+	claims, err := parseAccessToken(tokenString, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasScope(claims.Scope, PasswordChangeScope) {
+		return nil, ErrRestrictedToken
+	}
+
+	return claims, nil
+}
+
+// parseAccessToken validates an access token of any scope. Access tokens carry no audience, unlike the other
+// tokens signed by this package.
+func parseAccessToken(tokenString string, secret []byte) (*Claim, error) {
+	claims, err := parseJWTClaims(tokenString, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Audience != "" {
+		return nil, ErrNotAccessToken
+	}
+
+	return claims, nil
+}
+
+// checkDenylist rejects tokens whose jti has been revoked; a nil denylist accepts every token.
+func checkDenylist(denylist Store.TokenDenylist, claims *Claim) error {
+	if denylist == nil {
+		return nil
+	}
+
+	revoked, err := denylist.IsRevoked(claims.Id)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// parseJWTClaims checks the signature and expiry of a JWT and returns its claims, whatever their scope.
+func parseJWTClaims(tokenString string, secret []byte) (*Claim, error) {
 	claims := &Claim{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
 		return secret, nil
 	})
 
-	if err != nil || !token.Valid {
-		return false, err
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return true, nil
+	return claims, nil
+}
+
+// RevokeJWT adds the token's jti to the denylist until the token expires.
+// Tokens that are already expired need no revocation and are ignored.
+func RevokeJWT(tokenString string, secret []byte, denylist Store.TokenDenylist) error {
+	claims, err := parseAccessToken(tokenString, secret)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil
+		}
+		return err
+	}
+
+	if claims.Id == "" {
+		return errors.New("token has no jti claim")
+	}
+
+	return denylist.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
 }
 
 // GenerateRefreshToken generates a simple UUID as a refresh token.
 func GenerateRefreshToken(subject string, refreshTokenStore Store.RefreshTokenStore) (string, error) {
+	return generateRefreshTokenOnly(subject, refreshTokenStore, newTokenOptions(nil))
+}
+
+func generateRefreshTokenOnly(subject string, refreshTokenStore Store.RefreshTokenStore, options *tokenOptions) (string, error) {
 	// Generate a new UUID for the refresh token
 	refreshToken := uuid.New().String()
 
 	// Store the refresh token along with the associated subject
-	err := refreshTokenStore.Save(refreshToken, subject)
+	err := saveRefreshToken(refreshTokenStore, Store.RefreshTokenRecord{
+		Token:    refreshToken,
+		Subject:  subject,
+		ClientID: options.clientID,
+		IssuedAt: time.Now(),
+		Binding:  refreshTokenBinding(options),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -77,32 +200,164 @@ func GenerateRefreshToken(subject string, refreshTokenStore Store.RefreshTokenSt
 	return refreshToken, nil
 }
 
-func RefreshJWT(refreshTokenString string, secret []byte, refreshTokenStore Store.RefreshTokenStore) (string, string, error) {
-	// Validate refresh token
-	username, err := refreshTokenStore.FindSubject(refreshTokenString)
+// certificateBindingPrefix marks refresh token bindings to a client certificate rather than a DPoP key.
+const certificateBindingPrefix = "x5t#S256:"
+
+// refreshTokenBinding returns the key a refresh token is bound to: the DPoP key if there is one, otherwise the
+// client certificate.
+func refreshTokenBinding(options *tokenOptions) string {
+	if options.dpopJKT != "" {
+		return options.dpopJKT
+	}
+	if options.x5tS256 != "" {
+		return certificateBindingPrefix + options.x5tS256
+	}
+	return ""
+}
+
+// saveRefreshToken keeps the client and binding of record if the store supports it. A binding must never be
+// dropped, while a store that can't record the client merely skips the ownership check on revocation.
+func saveRefreshToken(refreshTokenStore Store.RefreshTokenStore, record Store.RefreshTokenRecord) error {
+	if recordStore, ok := refreshTokenStore.(Store.RecordRefreshTokenStore); ok {
+		return recordStore.SaveRecord(record)
+	}
+	if record.Binding != "" {
+		return fmt.Errorf("binding refresh tokens requires a store implementing RecordRefreshTokenStore, got %T", refreshTokenStore)
+	}
+	return refreshTokenStore.Save(record.Token, record.Subject)
+}
+
+// findRefreshToken looks up the record of a refresh token, with only the subject known for plain stores.
+func findRefreshToken(refreshToken string, refreshTokenStore Store.RefreshTokenStore) (*Store.RefreshTokenRecord, error) {
+	if recordStore, ok := refreshTokenStore.(Store.RecordRefreshTokenStore); ok {
+		return recordStore.FindRecord(refreshToken)
+	}
+
+	subject, err := refreshTokenStore.FindSubject(refreshToken)
 	if err != nil {
-		return "", "", errors.New("invalid refresh token")
+		return nil, err
+	}
+	return &Store.RefreshTokenRecord{Token: refreshToken, Subject: subject}, nil
+}
+
+// RefreshJWT exchanges a refresh token for a new token pair. A refresh token bound with WithDPoPKeyThumbprint or
+// WithCertificateBinding can only be used when the same key or certificate is passed again; the new tokens keep
+// that binding.
+func RefreshJWT(refreshTokenString string, secret []byte, refreshTokenStore Store.RefreshTokenStore, opts ...TokenOption) (string, string, error) {
+	options := newTokenOptions(opts)
+
+	username, newRefreshToken, err := rotateRefreshToken(refreshTokenString, refreshTokenStore, options)
+	if err != nil {
+		return "", "", err
 	}
 
 	// Generate new JWT
-	newJWT, err := generateJWTOnly(username, secret, time.Hour*72) // Adjust the expiry time as needed
+	newJWT, err := generateJWTOnly(username, secret, options.accessTokenExpiryOr(defaultAccessTokenExpiry), options)
 	if err != nil {
 		return "", "", err // Propagate the error if JWT generation fails
 	}
 
-	// Generate a new refresh token
-	newRefreshToken, err := GenerateRefreshToken(username, refreshTokenStore)
+	return newJWT, newRefreshToken, nil // Return both tokens
+}
+
+// rotateRefreshToken checks a refresh token against options and replaces it by a new one, returning the subject
+// and the new refresh token. options takes over the client of the old token.
+func rotateRefreshToken(refreshTokenString string, refreshTokenStore Store.RefreshTokenStore, options *tokenOptions) (string, string, error) {
+	// Validate refresh token
+	record, err := findRefreshToken(refreshTokenString, refreshTokenStore)
 	if err != nil {
-		return "", "", err // Propagate the error if refresh token generation fails
+		return "", "", errors.New("invalid refresh token")
 	}
+	username := record.Subject
 
-	invalidateOldRefreshToken(refreshTokenString, refreshTokenStore) // Invalidate the old refresh token
+	if record.Binding != "" && record.Binding != refreshTokenBinding(options) {
+		if strings.HasPrefix(record.Binding, certificateBindingPrefix) {
+			return "", "", ErrCertificateBindingMismatch
+		}
+		return "", "", ErrTokenBindingMismatch
+	}
 
-	return newJWT, newRefreshToken, nil // Return both tokens
+	// The new tokens stay with the client the refresh token was issued to
+	if record.ClientID != "" {
+		if options.clientID != "" && options.clientID != record.ClientID {
+			return "", "", errTokenNotOwnedByClient
+		}
+		options.clientID = record.ClientID
+	}
+
+	if options.userStore != nil {
+		user, err := options.userStore.FindUserByUsername(username)
+		if err != nil || user == nil {
+			return "", "", errors.New("invalid refresh token") // The user has been deleted
+		}
+
+		err = checkAccountStatus(user)
+		if err != nil {
+			return "", "", err
+		}
+
+		// Flagged after the refresh token was issued; unrestricted tokens would let the user skip the change
+		if user.MustChangePassword || !user.IsCredentialsNonExpired() {
+			return "", "", ErrPasswordChangeRequired
+		}
+	}
+
+	newRecord := Store.RefreshTokenRecord{
+		Token:    uuid.New().String(),
+		Subject:  username,
+		ClientID: options.clientID,
+		IssuedAt: time.Now(),
+		Binding:  refreshTokenBinding(options),
+	}
+
+	// Replace the refresh token, atomically if the store supports it
+	if rotatingStore, ok := refreshTokenStore.(Store.RotatingRefreshTokenStore); ok {
+		err = rotatingStore.Rotate(refreshTokenString, newRecord)
+		if err != nil {
+			return "", "", errors.New("invalid refresh token") // Another request used it first
+		}
+	} else {
+		err = saveRefreshToken(refreshTokenStore, newRecord)
+		if err != nil {
+			return "", "", err // Propagate the error if refresh token generation fails
+		}
+
+		invalidateOldRefreshToken(refreshTokenString, refreshTokenStore) // Invalidate the old refresh token
+	}
+
+	return username, newRecord.Token, nil
+}
+
+// RevokeRefreshToken removes a refresh token so it can no longer be exchanged for new tokens.
+func RevokeRefreshToken(refreshToken string, refreshTokenStore Store.RefreshTokenStore) error {
+	return refreshTokenStore.Delete(refreshToken)
+}
+
+// RevokeAllRefreshTokens removes every refresh token of subject, e.g. after its password changed.
+// The store must implement Store.SubjectIndexedRefreshTokenStore.
+func RevokeAllRefreshTokens(subject string, refreshTokenStore Store.RefreshTokenStore) error {
+	indexedStore, ok := refreshTokenStore.(Store.SubjectIndexedRefreshTokenStore)
+	if !ok {
+		return errors.New("refresh token store cannot look up tokens by subject")
+	}
+
+	records, err := indexedStore.FindBySubject(subject)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		err = refreshTokenStore.Delete(record.Token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func invalidateOldRefreshToken(refreshToken string, refreshTokenStore Store.RefreshTokenStore) {
-	err := refreshTokenStore.Delete(refreshToken)
+	err := RevokeRefreshToken(refreshToken, refreshTokenStore)
 	if err != nil {
 		log.Println("Failed to delete old refresh token")
 		return
@@ -112,7 +367,9 @@ func invalidateOldRefreshToken(refreshToken string, refreshTokenStore Store.Refr
 // ************************************************************************ //
 
 type JWTAuth struct {
-	secret []byte
+	secret       []byte
+	denylist     Store.TokenDenylist
+	dpopVerifier *DPoPVerifier
 }
 
 func NewJWTAuth(secret []byte) *JWTAuth {
@@ -121,18 +378,87 @@ func NewJWTAuth(secret []byte) *JWTAuth {
 	}
 }
 
+// WithDenylist rejects tokens whose jti has been revoked.
+func (j *JWTAuth) WithDenylist(denylist Store.TokenDenylist) *JWTAuth {
+	j.denylist = denylist
+	return j
+}
+
+// WithDPoP accepts DPoP-bound tokens, verifying the proof sent with every request.
+// Without it, tokens carrying a cnf.jkt claim are always rejected.
+func (j *JWTAuth) WithDPoP(dpopVerifier *DPoPVerifier) *JWTAuth {
+	j.dpopVerifier = dpopVerifier
+	return j
+}
+
 func (j *JWTAuth) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
 	// Extract the JWT from the Authorization header
-	tokenString := r.Header.Get("Authorization")
+	scheme, tokenString := obtainAuthorization(r)
 	if tokenString == "" {
 		return false, errors.New("missing Authorization header")
 	}
 
-	// Validate the JWT
-	isValid, err := ValidateJWT(tokenString, j.secret)
+	// Validate the JWT; restricted tokens are limited to the password change path by AuthChain
+	claims, err := parseAccessToken(tokenString, j.secret)
+	if err != nil {
+		return false, err
+	}
+
+	err = checkDenylist(j.denylist, claims)
 	if err != nil {
 		return false, err
 	}
 
-	return isValid, nil
+	err = j.verifyConfirmation(r, scheme, tokenString, claims)
+	if err != nil {
+		return false, err
+	}
+
+	setPrincipal(r, &Principal{
+		Subject:            claims.Subject,
+		PasswordChangeOnly: hasScope(claims.Scope, PasswordChangeScope),
+		IssuedAt:           time.Unix(claims.IssuedAt, 0),
+	})
+
+	return true, nil
+}
+
+// verifyConfirmation checks that the caller possesses the key a sender-constrained token is bound to.
+func (j *JWTAuth) verifyConfirmation(r *http.Request, scheme, tokenString string, claims *Claim) error {
+	confirmation := claims.Confirmation
+	if confirmation == nil {
+		confirmation = &Confirmation{}
+	}
+
+	err := j.verifyDPoPBinding(r, scheme, tokenString, confirmation.JKT)
+	if err != nil {
+		return err
+	}
+
+	return verifyCertificateBinding(r, confirmation.X5TS256)
+}
+
+func (j *JWTAuth) verifyDPoPBinding(r *http.Request, scheme, tokenString, jkt string) error {
+	if jkt == "" {
+		if strings.EqualFold(scheme, "DPoP") {
+			return fmt.Errorf("%w: DPoP scheme used with an unbound token", ErrInvalidDPoPProof)
+		}
+		return nil
+	}
+
+	// A DPoP-bound token presented as a plain bearer token must be rejected
+	if j.dpopVerifier == nil || !strings.EqualFold(scheme, "DPoP") {
+		return fmt.Errorf("%w: token requires the DPoP scheme", ErrInvalidDPoPProof)
+	}
+
+	proofJKT, err := j.dpopVerifier.VerifyProof(r, tokenString)
+	if err != nil {
+		return err
+	}
+
+	if proofJKT != jkt {
+		return ErrTokenBindingMismatch
+	}
+
+	return nil
 }
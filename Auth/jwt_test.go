@@ -0,0 +1,116 @@
+package Auth
+
+import (
+	"context"
+	"errors"
+	"github.com/ayushs-2k4/go-security/Auth/Store"
+	"github.com/ayushs-2k4/go-security/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJWTRejectsPasswordChangeTokens(t *testing.T) {
+	tokenString, err := GeneratePasswordChangeJWT("alice", testSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("GeneratePasswordChangeJWT: %v", err)
+	}
+
+	_, err = ParseJWT(tokenString, testSecret)
+	if !errors.Is(err, ErrRestrictedToken) {
+		t.Fatalf("ParseJWT: got %v, want ErrRestrictedToken", err)
+	}
+
+	valid, err := ValidateJWT(tokenString, testSecret)
+	if valid || !errors.Is(err, ErrRestrictedToken) {
+		t.Fatalf("ValidateJWT = %v, %v; want false, ErrRestrictedToken", valid, err)
+	}
+}
+
+func TestAccessTokenAuthenticationRejectsEmailVerificationTokens(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice@example.com", encodePassword(t, "secret"))
+	notifier := NewRecordingNotifier()
+
+	err := NewEmailVerification(userStore, testSecret, notifier, Store.NewInMemoryReplayCache()).
+		SendVerification(context.Background(), "alice@example.com")
+	if err != nil {
+		t.Fatalf("SendVerification: %v", err)
+	}
+	notification, _ := notifier.Last("alice@example.com")
+	verificationToken := notification.Token
+
+	_, err = ParseJWT(verificationToken, testSecret)
+	if !errors.Is(err, ErrNotAccessToken) {
+		t.Fatalf("ParseJWT: got %v, want ErrNotAccessToken", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: verificationToken})
+	authenticated, err := NewJWTCookieSession(testSecret, time.Minute).Authenticate(httptest.NewRecorder(), req)
+	if authenticated || !errors.Is(err, ErrNotAccessToken) {
+		t.Fatalf("JWTCookieSession.Authenticate = %v, %v; want false, ErrNotAccessToken", authenticated, err)
+	}
+}
+
+func TestJWTCookieSessionRejectsRevokedTokens(t *testing.T) {
+	denylist := Store.NewInMemoryTokenDenylist()
+	cookieSession := NewJWTCookieSession(testSecret, time.Minute).WithDenylist(denylist)
+
+	w := httptest.NewRecorder()
+	err := cookieSession.EstablishSession(w, httptest.NewRequest("POST", "/login", nil), &model.User{Username: "alice"})
+	if err != nil {
+		t.Fatalf("EstablishSession: %v", err)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	err = RevokeJWT(cookie.Value, testSecret, denylist)
+	if err != nil {
+		t.Fatalf("RevokeJWT: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	authenticated, err := cookieSession.Authenticate(httptest.NewRecorder(), req)
+	if authenticated || !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Authenticate = %v, %v; want false, ErrTokenRevoked", authenticated, err)
+	}
+}
+
+func TestRefreshJWTRefusesUsersWhoMustChangePassword(t *testing.T) {
+	userStore := Store.NewInMemoryUserStore()
+	userStore.AddUser("alice", encodePassword(t, "secret"))
+	refreshTokenStore := Store.NewInMemoryRefreshTokenStore()
+
+	_, refreshToken, err := GenerateJWT("alice", testSecret, time.Minute, refreshTokenStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flagged after login, e.g. by an administrator
+	user, _ := userStore.FindUserByUsername("alice")
+	user.MustChangePassword = true
+	userStore.SaveUser(user)
+
+	_, _, err = RefreshJWT(refreshToken, testSecret, refreshTokenStore, WithAccountStatusCheck(userStore))
+	if !errors.Is(err, ErrPasswordChangeRequired) {
+		t.Fatalf("RefreshJWT: got %v, want %v", err, ErrPasswordChangeRequired)
+	}
+
+	w := httptest.NewRecorder()
+	NewRefreshHandler(testSecret, time.Minute, refreshTokenStore).WithAccountStatusCheck(userStore).
+		ServeHTTP(w, httptest.NewRequest("POST", "/refresh", strings.NewReader(`{"refreshToken":"`+refreshToken+`"}`)))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "password_change_required") {
+		t.Fatalf("RefreshHandler: got status %d: %s", w.Code, w.Body)
+	}
+
+	user.MustChangePassword = false
+	user.CredentialsExpired = true
+	userStore.SaveUser(user)
+	_, _, err = RefreshJWT(refreshToken, testSecret, refreshTokenStore, WithAccountStatusCheck(userStore))
+	if !errors.Is(err, ErrPasswordChangeRequired) {
+		t.Fatalf("RefreshJWT with expired credentials: got %v, want %v", err, ErrPasswordChangeRequired)
+	}
+}
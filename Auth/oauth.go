@@ -0,0 +1,35 @@
+package Auth
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// OAuthErrorResponse is the error body defined by RFC 6749 section 5.2.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	writeJSON(w, status, OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+	}
+}
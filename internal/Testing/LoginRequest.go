@@ -1,6 +0,0 @@
-package Testing
-
-type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Session is a server-side login session, referenced by the ID stored in the session cookie.
+type Session struct {
+	ID             string
+	Subject        string
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+	ExpiresAt      time.Time // when the session expires unless it is accessed again, zero for never
+	Attributes     map[string]string
+}
@@ -1,14 +1,71 @@
 package model
 
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned by UserStore implementations for unknown usernames.
+	ErrUserNotFound = errors.New("user not found")
+)
+
 type User struct {
 	ID       string
 	Username string
 	Password string
+
+	// Account status; the zero value is an active account.
+	Disabled           bool
+	Locked             bool
+	AccountExpiresAt   time.Time // zero means the account never expires
+	CredentialsExpired bool
+
+	// PasswordChangedAt is when the current password was set; zero if unknown.
+	PasswordChangedAt time.Time
+
+	// PasswordHistory holds the hashes of previous passwords, most recent first.
+	PasswordHistory []string
+
+	Roles []string
+
+	// MustChangePassword restricts the next login to changing the password, e.g. after an admin reset.
+	MustChangePassword bool
+
+	// EmailVerified is set once the user proved ownership of the email address used as username.
+	EmailVerified bool
+}
+
+// IsEnabled indicates whether the user is enabled or disabled.
+func (u *User) IsEnabled() bool {
+	return !u.Disabled
+}
+
+// IsAccountNonLocked indicates whether the user is locked or unlocked.
+func (u *User) IsAccountNonLocked() bool {
+	return !u.Locked
+}
+
+// IsAccountNonExpired indicates whether the user's account has expired.
+func (u *User) IsAccountNonExpired() bool {
+	return u.AccountExpiresAt.IsZero() || time.Now().Before(u.AccountExpiresAt)
+}
+
+// HasRole indicates whether the user has the given role.
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// IsCredentialsNonExpired indicates whether the user's credentials have expired.
+func (u *User) IsCredentialsNonExpired() bool {
+	return !u.CredentialsExpired
 }
 
 // UserStore defines methods for user storage.
 type UserStore interface {
-	// FindUserByUsername retrieves a user by their email.
+	// FindUserByUsername retrieves a user by their username. Unknown users must yield ErrUserNotFound (possibly
+	// wrapped) and no other error, as any other error is treated as a failure of the store itself.
 	FindUserByUsername(username string) (*User, error)
 
 	// SaveRefreshToken stores the refresh token for a user.
@@ -16,4 +73,14 @@ type UserStore interface {
 
 	// ValidateRefreshToken checks if the refresh token is valid.
 	ValidateRefreshToken(refreshToken string) (string, error) // returns email if valid
+
+	// UpdatePassword replaces the stored password hash of a user, e.g. when rehashing the same password.
+	UpdatePassword(username, encodedPassword string) error
+
+	// ChangePassword stores a new password chosen by the user: the previous hash moves to PasswordHistory,
+	// PasswordChangedAt is set to now, and MustChangePassword and CredentialsExpired are cleared.
+	ChangePassword(username, encodedPassword string) error
+
+	// MarkEmailVerified records that the user proved ownership of their email address.
+	MarkEmailVerified(username string) error
 }